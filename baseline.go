@@ -0,0 +1,72 @@
+package mgrt
+
+import (
+	"context"
+	"errors"
+)
+
+// Baseline records the given revisions in the revision log of the given
+// database as having been performed, without executing them. This is used for
+// adopting mgrt on a database whose schema already matches the given
+// revisions. Revisions that have already been performed are skipped. The
+// revisions are recorded inside of a single transaction, and will have
+// Baselined set when retrieved from the revision log. An exclusive lock is
+// acquired on the database via AcquireLock before any of the revisions are
+// recorded.
+func Baseline(db *DB, revs ...*Revision) error {
+	return BaselineContext(context.Background(), db, revs...)
+}
+
+// BaselineContext records the given revisions in the revision log of the
+// given database using the given context. This behaves the same as Baseline.
+func BaselineContext(ctx context.Context, db *DB, revs ...*Revision) error {
+	l, err := AcquireLockContext(ctx, db)
+
+	if err != nil {
+		return err
+	}
+
+	defer l.Release()
+
+	var c Collection
+
+	for _, rev := range revs {
+		c.Put(rev)
+	}
+
+	baseline := make([]*Revision, 0, c.Len())
+
+	for _, rev := range c.Slice() {
+		if err := RevisionPerformedContext(ctx, db, rev); err != nil {
+			if errors.Is(err, ErrPerformed) {
+				continue
+			}
+			return err
+		}
+		baseline = append(baseline, rev)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	for _, rev := range baseline {
+		var sum string
+
+		if rev.Func == nil {
+			sum = checksum(rev.SQL)
+		}
+
+		if err := rev.log(ctx, db, tx, sum, 0, true, false); err != nil {
+			tx.Rollback()
+
+			return &RevisionError{
+				ID:  rev.Slug(),
+				Err: err,
+			}
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,75 @@
+package mgrt
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func Test_Baseline(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tests := []struct {
+		id      string
+		sql     string
+		perform bool
+	}{
+		{"20060102150405", "CREATE TABLE users ( id INT NOT NULL UNIQUE );", true},
+		{"20060102150406", "CREATE TABLE posts ( id INT NOT NULL UNIQUE );", false},
+		{"20060102150407", "CREATE TABLE comments ( id INT NOT NULL UNIQUE );", false},
+	}
+
+	revs := make([]*Revision, 0, len(tests))
+
+	for _, test := range tests {
+		rev := NewRevision("Andrew", "")
+		rev.ID = test.id
+		rev.SQL = test.sql
+
+		if test.perform {
+			if err := rev.Perform(db); err != nil {
+				t.Fatal(err)
+			}
+		}
+		revs = append(revs, rev)
+	}
+
+	if err := Baseline(db, revs...); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		rev, err := GetRevision(db, test.id)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rev.Baselined == test.perform {
+			t.Errorf("unexpected revision baselined for %s, expected=%v, got=%v\n", test.id, !test.perform, rev.Baselined)
+		}
+	}
+
+	// The SQL of a baselined revision should not have been executed.
+	if _, err := db.Exec("SELECT id FROM posts"); err == nil {
+		t.Fatalf("expected table posts to not exist\n")
+	}
+
+	if err := VerifyRevisions(db, revs...); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,183 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andrewpillar/mgrt/v3"
+)
+
+var BaselineCmd = &Command{
+	Usage: "baseline [revision]",
+	Short: "record the local revisions as performed without running them",
+	Long: `Baseline will record the local revisions in the revision log of the given
+database as having been performed, without running them. This is used for
+adopting mgrt on a database whose schema already matches the local revisions.
+If a revision is given, then every local revision up to and including that
+revision is recorded, otherwise all of the local revisions are recorded.
+Revisions that have already been performed are skipped. Baselined revisions are
+marked as such in "mgrt log".
+
+The -c flag specifies the category of revisions to baseline. If not given, then
+the default revisions will be baselined. If the given revision is in a category,
+then that category is used.
+
+The database to connect to is specified via the -type and -dsn flags, or via
+the -db flag if a database connection has been configured via the "mgrt db"
+command.
+
+The -table flag specifies the table the revision log is stored in, by default
+this is mgrt_revisions. This can be qualified with a schema, for example,
+
+    -table myschema.mgrt_revisions
+
+The -type flag specifies the type of database to connect to, it will be one of,
+
+    mysql
+    postgresql
+    sqlite3
+
+The -dsn flag specifies the data source name for the database. This will vary
+depending on the type of database you're connecting to.
+
+mysql and postgresql both allow for the URI connection string, such as,
+
+    type://[user[:password]@][host]:[port][,...][/dbname][?param1=value1&...]
+
+where type would either be mysql or postgresql. The postgresql type also allows
+for the DSN string such as,
+
+    host=localhost port=5432 dbname=mydb connect_timeout=10
+
+sqlite3 however will accept a filepath, or the :memory: string, for example,
+
+    -dsn :memory:`,
+	Run: baselineCmd,
+}
+
+func baselineCmd(cmd *Command, args []string) {
+	info, err := os.Stat(revisionsDir)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "%s: no revisions to baseline\n", cmd.Argv0)
+			os.Exit(1)
+		}
+
+		fmt.Fprintf(os.Stderr, "%s: failed to baseline revisions: %s\n", cmd.Argv0, err)
+		os.Exit(1)
+	}
+
+	if !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "%s: %s is not a directory\n", cmd.Argv0, revisionsDir)
+		os.Exit(1)
+	}
+
+	var (
+		typ      string
+		dsn      string
+		category string
+		dbname   string
+		table    string
+	)
+
+	fs := flag.NewFlagSet(cmd.Argv0, flag.ExitOnError)
+	fs.StringVar(&typ, "type", "", "the database type one of postgresql, sqlite3")
+	fs.StringVar(&dsn, "dsn", "", "the dsn for the database to baseline the revisions against")
+	fs.StringVar(&category, "c", "", "the category of revisions to baseline")
+	fs.StringVar(&dbname, "db", "", "the database to connect to")
+	fs.StringVar(&table, "table", "", "the table the revision log is stored in")
+	fs.Parse(args[1:])
+
+	if dbname != "" {
+		it, err := getdbitem(dbname)
+
+		if err != nil {
+			if os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "%s: database %s does not exist\n", cmd.Argv0, dbname)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+
+		typ = it.Type
+		dsn = it.DSN
+
+		if table == "" {
+			table = it.Table
+		}
+	}
+
+	if typ == "" {
+		fmt.Fprintf(os.Stderr, "%s: database not specified\n", cmd.Argv0)
+		os.Exit(1)
+	}
+
+	if dsn == "" {
+		fmt.Fprintf(os.Stderr, "%s: database not specified\n", cmd.Argv0)
+		os.Exit(1)
+	}
+
+	var id string
+
+	if fs.NArg() > 0 {
+		id = fs.Arg(0)
+
+		if i := strings.LastIndex(id, "/"); i >= 0 {
+			category = id[:i]
+			id = id[i+1:]
+		}
+	}
+
+	dir := revisionsDir
+
+	if category != "" {
+		dir = filepath.Join(revisionsDir, category)
+	}
+
+	revs0, err := mgrt.LoadRevisions(dir)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+		os.Exit(1)
+	}
+
+	revs := make([]*mgrt.Revision, 0, len(revs0))
+	found := id == ""
+
+	for _, rev := range revs0 {
+		if rev.Category != category {
+			continue
+		}
+
+		if id != "" && rev.ID > id {
+			continue
+		}
+
+		found = found || rev.ID == id
+		revs = append(revs, rev)
+	}
+
+	if !found {
+		fmt.Fprintf(os.Stderr, "%s: revision %s not found\n", cmd.Argv0, fs.Arg(0))
+		os.Exit(1)
+	}
+
+	db, err := mgrt.Open(typ, dsn, openOptions(table)...)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+		os.Exit(1)
+	}
+
+	defer db.Close()
+
+	if err := mgrt.Baseline(db, revs...); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: failed to baseline revisions: %s\n", cmd.Argv0, err)
+		os.Exit(1)
+	}
+}
@@ -6,6 +6,10 @@ import (
 	"sort"
 )
 
+// Build is the version of mgrt that is running, this is recorded in the
+// revision log alongside each revision that is performed.
+var Build string
+
 type Command struct {
 	Argv0 string // Argv0 is the name of the process running the command.
 	Usage string // Usage is the usage line of the command.
@@ -3,15 +3,20 @@ package internal
 import (
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/andrewpillar/mgrt/v3"
 )
 
 type dbItem struct {
-	Name string
-	Type string
-	DSN  string
+	Name  string
+	Type  string
+	DSN   string
+	Table string
 }
 
 var (
@@ -22,9 +27,14 @@ var (
 	}
 
 	DBSetCmd = &Command{
-		Usage: "set <name> <type> <dsn>",
+		Usage: "set [-table table] <name> <type> <dsn>",
 		Short: "set the database connection",
-		Run:   dbSetCmd,
+		Long: `Set will configure a database connection with the given name, type, and dsn.
+The -table flag can be given to specify the table the revision log is stored in
+for the database, this can be qualified with a schema, for example,
+
+    -table myschema.mgrt_revisions`,
+		Run: dbSetCmd,
 	}
 
 	DBRmCmd = &Command{
@@ -61,6 +71,30 @@ func mgrtdir() (string, error) {
 	return dir, nil
 }
 
+// openOptions returns the options for opening a database that will use the
+// given table for the revision log. The table can be qualified with a schema,
+// such as schema.table. The current Build is recorded as the version of mgrt,
+// and the Logger is used for logging, if set.
+func openOptions(table string) []mgrt.Option {
+	opts := []mgrt.Option{
+		mgrt.WithVersion(Build),
+	}
+
+	if Logger != nil {
+		opts = append(opts, mgrt.WithLogger(Logger))
+	}
+
+	if table == "" {
+		return opts
+	}
+
+	if i := strings.LastIndex(table, "."); i > 0 {
+		opts = append(opts, mgrt.WithSchema(table[:i]))
+		table = table[i+1:]
+	}
+	return append(opts, mgrt.WithTable(table))
+}
+
 func getdbitem(name string) (dbItem, error) {
 	dir, err := mgrtdir()
 
@@ -155,8 +189,16 @@ func dbLsCmd(cmd *Command, args []string) {
 }
 
 func dbSetCmd(cmd *Command, args []string) {
+	var table string
+
+	fs := flag.NewFlagSet(cmd.Argv0, flag.ExitOnError)
+	fs.StringVar(&table, "table", "", "the table the revision log is stored in")
+	fs.Parse(args[1:])
+
+	args = append(args[:1], fs.Args()...)
+
 	if len(args[1:]) != 3 {
-		fmt.Fprintf(os.Stderr, "usage: %s <name> <type> <dsn>\n", cmd.Argv0)
+		fmt.Fprintf(os.Stderr, "usage: %s [-table table] <name> <type> <dsn>\n", cmd.Argv0)
 		os.Exit(1)
 	}
 
@@ -168,9 +210,10 @@ func dbSetCmd(cmd *Command, args []string) {
 	}
 
 	it := dbItem{
-		Name: args[1],
-		Type: args[2],
-		DSN:  args[3],
+		Name:  args[1],
+		Type:  args[2],
+		DSN:   args[3],
+		Table: table,
 	}
 
 	fname := filepath.Join(dir, it.Name)
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/andrewpillar/mgrt/v3"
+)
+
+// Format is the format that the output of the listing commands is written in,
+// this will be one of text, json, or ndjson. If empty, then text is used.
+var Format string
+
+// revisionObject is the structured form of a revision written when the output
+// format is json or ndjson.
+type revisionObject struct {
+	Slug        string     `json:"slug"`
+	ID          string     `json:"id"`
+	Category    string     `json:"category"`
+	Author      string     `json:"author"`
+	Comment     string     `json:"comment"`
+	SQL         string     `json:"sql"`
+	PerformedAt *time.Time `json:"performed_at"`
+}
+
+// statusObject is the structured form of the status of a revision.
+type statusObject struct {
+	revisionObject
+
+	State string `json:"state"`
+}
+
+// failureObject is the structured form of a failed attempt at performing a
+// revision.
+type failureObject struct {
+	Slug        string    `json:"slug"`
+	ID          string    `json:"id"`
+	Category    string    `json:"category"`
+	Message     string    `json:"message"`
+	Duration    int64     `json:"duration_ms"`
+	PerformedBy string    `json:"performed_by"`
+	Version     string    `json:"version"`
+	FailedAt    time.Time `json:"failed_at"`
+}
+
+// runFailure is a revision that failed to be performed in the summary of the
+// run command.
+type runFailure struct {
+	Slug  string `json:"slug"`
+	Error string `json:"error"`
+}
+
+// runSummary is the structured summary of the revisions performed by the run
+// command.
+type runSummary struct {
+	Applied []string     `json:"applied"`
+	Skipped []string     `json:"skipped"`
+	Failed  []runFailure `json:"failed"`
+}
+
+// ValidFormat checks to see if the given output format is supported.
+func ValidFormat(format string) error {
+	switch format {
+	case "", "text", "json", "ndjson":
+		return nil
+	default:
+		return errors.New("unknown format " + format)
+	}
+}
+
+// structured reports whether the output format is json or ndjson.
+func structured() bool {
+	return Format == "json" || Format == "ndjson"
+}
+
+// newRevisionObject returns the structured form of the given revision. The
+// performed_at field will be null for revisions that have not been performed.
+func newRevisionObject(rev *mgrt.Revision) revisionObject {
+	obj := revisionObject{
+		Slug:     rev.Slug(),
+		ID:       rev.ID,
+		Category: rev.Category,
+		Author:   rev.Author,
+		Comment:  rev.Comment,
+		SQL:      rev.SQL,
+	}
+
+	if !rev.PerformedAt.IsZero() {
+		obj.PerformedAt = &rev.PerformedAt
+	}
+	return obj
+}
+
+// newFailureObject returns the structured form of the given failure.
+func newFailureObject(f *mgrt.Failure) failureObject {
+	return failureObject{
+		Slug:        f.Slug(),
+		ID:          f.ID,
+		Category:    f.Category,
+		Message:     f.Message,
+		Duration:    f.Duration.Milliseconds(),
+		PerformedBy: f.PerformedBy,
+		Version:     f.Version,
+		FailedAt:    f.FailedAt,
+	}
+}
+
+// newEncoder returns a JSON encoder that writes to stdout. HTML escaping is
+// disabled, so values such as the email in an author are written as is.
+func newEncoder() *json.Encoder {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	return enc
+}
+
+// printObject writes the given object to stdout in the output format.
+func printObject(v interface{}) error {
+	enc := newEncoder()
+
+	if Format == "json" {
+		enc.SetIndent("", "    ")
+	}
+	return enc.Encode(v)
+}
+
+// printObjects writes the given list of objects to stdout in the output
+// format. For json this will be a single array, for ndjson this will be each
+// object on its own line.
+func printObjects(objs []interface{}) error {
+	if Format == "ndjson" {
+		enc := newEncoder()
+
+		for _, obj := range objs {
+			if err := enc.Encode(obj); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return printObject(objs)
+}
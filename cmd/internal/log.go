@@ -1,10 +1,10 @@
 package internal
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/andrewpillar/mgrt/v3"
@@ -15,9 +15,49 @@ var LogCmd = &Command{
 	Short: "log the performed revisions",
 	Long: `Log displays all of the revisions that have been performed in the given
 database. The -n flag can be given to limit the number of revisions that are
-shown in the log. The database to connect to is specified via the -type and
--dsn flags, or via the -db flag if a database connection has been configured
-via the "mgrt db" command.
+shown in the log. Revisions that were recorded via "mgrt baseline" or "mgrt
+mark" without being performed are shown as baselined or marked respectively.
+Each entry shows how long the revision took to perform, who performed it, and
+the version of mgrt it was performed with.
+
+The revisions shown can be filtered with the following flags, which can be
+combined,
+
+    -c       the category of the revisions
+    -author  the revisions whose author contains the given string
+    -since   the revisions performed at or after the given time
+    -until   the revisions performed at or before the given time
+    -grep    the revisions whose comment or SQL contains the given string
+
+the -author and -grep flags are case insensitive. The -since and -until flags
+accept a date such as 2006-01-02, a date and time such as 2006-01-02 15:04:05,
+an RFC3339 time, or a duration such as 72h for a time relative to now. The
+-reverse flag will show the revisions in the order they were performed, oldest
+first. The filter flags do not apply to -failures.
+
+The -failures flag will display the failed attempts at performing revisions
+instead, along with the error that caused each failure. The database to
+connect to is specified via the -type and -dsn flags, or via the -db flag if a
+database connection has been configured via the "mgrt db" command.
+
+The -format flag specifies the format to display the revisions in. This can be
+one of text, json, or ndjson, see "mgrt help", or a template that is rendered
+via text/template for each revision, for example,
+
+    -format '{{.Slug}} {{.Author}} {{date "rfc3339" .PerformedAt}}'
+
+the following helper functions are available to the template,
+
+    title   the title of the given comment
+    date    format the given time in the given layout, such as rfc3339
+    indent  indent each line of the given string by the given spaces
+
+With -failures, each failure is rendered via the template instead.
+
+The -table flag specifies the table the revision log is stored in, by default
+this is mgrt_revisions. This can be qualified with a schema, for example,
+
+    -table myschema.mgrt_revisions
 
 The -type flag specifies the type of database to connect to, it will be one of,
 
@@ -45,19 +85,78 @@ sqlite3 however will accept a filepath, or the :memory: string, for example,
 
 func logCmd(cmd *Command, args []string) {
 	var (
-		typ    string
-		dsn    string
-		dbname string
-		n      int
+		typ      string
+		dsn      string
+		dbname   string
+		table    string
+		format   string
+		category string
+		author   string
+		since    string
+		until    string
+		grep     string
+		n        int
+		failures bool
+		reverse  bool
 	)
 
 	fs := flag.NewFlagSet(cmd.Argv0, flag.ExitOnError)
 	fs.StringVar(&typ, "type", "", "the database type one of postgresql, sqlite3")
 	fs.StringVar(&dsn, "dsn", "", "the dsn for the database to run the revisions against")
 	fs.StringVar(&dbname, "db", "", "the database to connect to")
+	fs.StringVar(&table, "table", "", "the table the revision log is stored in")
 	fs.IntVar(&n, "n", 0, "the number of entries to show")
+	fs.BoolVar(&failures, "failures", false, "show the failed attempts at performing revisions")
+	fs.StringVar(&format, "format", "", "the format to display the revisions in")
+	fs.StringVar(&category, "c", "", "the category of revisions to show")
+	fs.StringVar(&author, "author", "", "show the revisions whose author contains the given string")
+	fs.StringVar(&since, "since", "", "show the revisions performed at or after the given time")
+	fs.StringVar(&until, "until", "", "show the revisions performed at or before the given time")
+	fs.StringVar(&grep, "grep", "", "show the revisions whose comment or SQL contains the given string")
+	fs.BoolVar(&reverse, "reverse", false, "show the revisions oldest first")
 	fs.Parse(args[1:])
 
+	filter := mgrt.Filter{
+		Category: category,
+		Author:   author,
+		Grep:     grep,
+		Reverse:  reverse,
+		Limit:    n,
+	}
+
+	if since != "" {
+		t, err := parseTime(since)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: invalid -since: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+		filter.Since = t
+	}
+
+	if until != "" {
+		t, err := parseTime(until)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: invalid -until: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+		filter.Until = t
+	}
+
+	def := logTemplate
+
+	if failures {
+		def = failureTemplate
+	}
+
+	tmpl, err := outputTemplate(format, def)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: invalid format: %s\n", cmd.Argv0, err)
+		os.Exit(1)
+	}
+
 	if dbname != "" {
 		it, err := getdbitem(dbname)
 
@@ -72,6 +171,10 @@ func logCmd(cmd *Command, args []string) {
 
 		typ = it.Type
 		dsn = it.DSN
+
+		if table == "" {
+			table = it.Table
+		}
 	}
 
 	if typ == "" {
@@ -84,7 +187,7 @@ func logCmd(cmd *Command, args []string) {
 		os.Exit(1)
 	}
 
-	db, err := mgrt.Open(typ, dsn)
+	db, err := mgrt.Open(typ, dsn, openOptions(table)...)
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
@@ -93,24 +196,86 @@ func logCmd(cmd *Command, args []string) {
 
 	defer db.Close()
 
-	revs, err := mgrt.GetRevisions(db, n)
+	if failures {
+		fails, err := mgrt.GetFailures(db, n)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: failed to get failures: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+
+		if structured() {
+			objs := make([]interface{}, 0, len(fails))
+
+			for _, f := range fails {
+				objs = append(objs, newFailureObject(f))
+			}
+
+			if err := printObjects(objs); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		for _, f := range fails {
+			if err := render(tmpl, f); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+				os.Exit(1)
+			}
+		}
+		return
+	}
+
+	revs, err := mgrt.QueryRevisions(db, filter)
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s: failed to get revisions: %s\n", cmd.Argv0, err)
 		os.Exit(1)
 	}
 
+	if structured() {
+		objs := make([]interface{}, 0, len(revs))
+
+		for _, rev := range revs {
+			objs = append(objs, newRevisionObject(rev))
+		}
+
+		if err := printObjects(objs); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	for _, rev := range revs {
-		fmt.Println("revision", rev.Slug())
-		fmt.Println("Author:    ", rev.Author)
-		fmt.Println("Performed: ", rev.PerformedAt.Format(time.ANSIC))
-		fmt.Println()
+		if err := render(tmpl, rev); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// timeLayouts are the layouts of the times accepted by the -since and -until
+// flags.
+var timeLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+}
 
-		lines := strings.Split(rev.Comment, "\n")
+// parseTime parses the given time for the -since and -until flags. This will
+// either be a time in one of the timeLayouts in the local time zone, or a
+// duration which is subtracted from the current time.
+func parseTime(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
 
-		for _, line := range lines {
-			fmt.Println("   ", line)
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
 		}
-		fmt.Println()
 	}
+	return time.Time{}, errors.New("unrecognized time " + s)
 }
@@ -0,0 +1,38 @@
+package internal
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+)
+
+// Logger is the logger passed to each database that is opened, for logging
+// the revisions performed against it. If nil, then nothing is logged.
+var Logger *slog.Logger
+
+// NewLogger returns a logger that writes logs in the given format to the given
+// writer, at the given level or higher. The format will be one of text or json,
+// and the level will be one of debug, info, warn, or error. If the format is
+// empty, then text is used. If the level is empty, then info is used.
+func NewLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, errors.New("unknown log level " + level)
+		}
+	}
+
+	opts := &slog.HandlerOptions{
+		Level: lvl,
+	}
+
+	switch format {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, errors.New("unknown log format " + format)
+	}
+}
@@ -11,21 +11,49 @@ import (
 var LsCmd = &Command{
 	Usage: "ls",
 	Short: "list revisions",
-	Long:  `List will display all of the revisions you have.`,
-	Run:   lsCmd,
+	Long: `List will display all of the revisions you have.
+
+The -c flag specifies the category of revisions to list.
+
+The -format flag specifies the format to display the revisions in. This can be
+one of text, json, or ndjson, see "mgrt help", or a template that is rendered
+via text/template for each revision, for example,
+
+    -format '{{.Slug}} {{title .Comment}}'
+
+the following helper functions are available to the template,
+
+    title   the title of the given comment
+    date    format the given time in the given layout, such as rfc3339
+    indent  indent each line of the given string by the given spaces`,
+	Run: lsCmd,
 }
 
 func lsCmd(cmd *Command, args []string) {
-	var category string
+	var (
+		category string
+		format   string
+	)
 
 	fs := flag.NewFlagSet(cmd.Argv0, flag.ExitOnError)
 	fs.StringVar(&category, "c", "", "the category to list the revisions of")
+	fs.StringVar(&format, "format", "", "the format to display the revisions in")
 	fs.Parse(args[1:])
 
+	tmpl, err := outputTemplate(format, "")
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: invalid format: %s\n", cmd.Argv0, err)
+		os.Exit(1)
+	}
+
 	info, err := os.Stat(revisionsDir)
 
 	if err != nil {
 		if os.IsNotExist(err) {
+			if structured() {
+				printObjects(make([]interface{}, 0))
+			}
 			return
 		}
 
@@ -55,6 +83,36 @@ func lsCmd(cmd *Command, args []string) {
 
 	show := true
 
+	if tmpl != nil {
+		for _, r := range revs {
+			if category != "" && r.Category != category {
+				continue
+			}
+
+			if err := render(tmpl, r); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+				os.Exit(1)
+			}
+		}
+		return
+	}
+
+	if structured() {
+		objs := make([]interface{}, 0, len(revs))
+
+		for _, r := range revs {
+			if category == "" || r.Category == category {
+				objs = append(objs, newRevisionObject(r))
+			}
+		}
+
+		if err := printObjects(objs); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: failed to list revisions: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	for _, r := range revs {
 		if category != "" {
 			show = r.Category == category
@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/andrewpillar/mgrt/v3"
+)
+
+var MarkCmd = &Command{
+	Usage: "mark <revision>",
+	Short: "record a revision as performed without running it",
+	Long: `Mark will record the given revision in the revision log of the given database
+as having been performed, without running it. This should only be used for
+repairing the revision log, for example if the revision was performed by hand.
+The -y flag must be given to confirm the revision should be recorded.
+
+The database to connect to is specified via the -type and -dsn flags, or via
+the -db flag if a database connection has been configured via the "mgrt db"
+command.
+
+The -table flag specifies the table the revision log is stored in, by default
+this is mgrt_revisions. This can be qualified with a schema, for example,
+
+    -table myschema.mgrt_revisions
+
+The -type flag specifies the type of database to connect to, it will be one of,
+
+    mysql
+    postgresql
+    sqlite3
+
+The -dsn flag specifies the data source name for the database. This will vary
+depending on the type of database you're connecting to.
+
+mysql and postgresql both allow for the URI connection string, such as,
+
+    type://[user[:password]@][host]:[port][,...][/dbname][?param1=value1&...]
+
+where type would either be mysql or postgresql. The postgresql type also allows
+for the DSN string such as,
+
+    host=localhost port=5432 dbname=mydb connect_timeout=10
+
+sqlite3 however will accept a filepath, or the :memory: string, for example,
+
+    -dsn :memory:`,
+	Run: markCmd,
+}
+
+func markCmd(cmd *Command, args []string) {
+	var (
+		typ     string
+		dsn     string
+		dbname  string
+		table   string
+		confirm bool
+	)
+
+	fs := flag.NewFlagSet(cmd.Argv0, flag.ExitOnError)
+	fs.StringVar(&typ, "type", "", "the database type one of postgresql, sqlite3")
+	fs.StringVar(&dsn, "dsn", "", "the dsn for the database to connect to")
+	fs.StringVar(&dbname, "db", "", "the database to connect to")
+	fs.StringVar(&table, "table", "", "the table the revision log is stored in")
+	fs.BoolVar(&confirm, "y", false, "confirm the revision should be recorded")
+	fs.Parse(args[1:])
+
+	if fs.NArg() < 1 {
+		fmt.Fprintf(os.Stderr, "usage: %s <revision>\n", cmd.Argv0)
+		os.Exit(1)
+	}
+
+	if !confirm {
+		fmt.Fprintf(os.Stderr, "%s: refusing to mark revision without -y\n", cmd.Argv0)
+		os.Exit(1)
+	}
+
+	id := fs.Arg(0)
+
+	rev, err := mgrt.OpenRevision(revisionPath(id))
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: failed to open revision %s: %s\n", cmd.Argv0, id, err)
+		os.Exit(1)
+	}
+
+	if dbname != "" {
+		it, err := getdbitem(dbname)
+
+		if err != nil {
+			if os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "%s: database %s does not exist\n", cmd.Argv0, dbname)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+
+		typ = it.Type
+		dsn = it.DSN
+
+		if table == "" {
+			table = it.Table
+		}
+	}
+
+	if typ == "" {
+		fmt.Fprintf(os.Stderr, "%s: database not specified\n", cmd.Argv0)
+		os.Exit(1)
+	}
+
+	if dsn == "" {
+		fmt.Fprintf(os.Stderr, "%s: database not specified\n", cmd.Argv0)
+		os.Exit(1)
+	}
+
+	db, err := mgrt.Open(typ, dsn, openOptions(table)...)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+		os.Exit(1)
+	}
+
+	defer db.Close()
+
+	if err := mgrt.Mark(db, rev); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: failed to mark revision: %s\n", cmd.Argv0, err)
+		os.Exit(1)
+	}
+}
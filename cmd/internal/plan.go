@@ -0,0 +1,189 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andrewpillar/mgrt/v3"
+)
+
+var PlanCmd = &Command{
+	Usage: "plan [revisions,...]",
+	Short: "show the revisions that would be run",
+	Long: `Plan will display the revisions that would be performed against the given
+database by "mgrt run", in the order they would be performed in, along with the
+SQL of each revision. Nothing is performed against the database. If no revisions
+are given, then all of the local revisions will be planned.
+
+The -c flag specifies the category of revisions to plan. If not given, then the
+default revisions will be planned.
+
+The database to connect to is specified via the -type and -dsn flags, or via
+the -db flag if a database connection has been configured via the "mgrt db"
+command.
+
+The -table flag specifies the table the revision log is stored in, by default
+this is mgrt_revisions. This can be qualified with a schema, for example,
+
+    -table myschema.mgrt_revisions
+
+The -type flag specifies the type of database to connect to, it will be one of,
+
+    mysql
+    postgresql
+    sqlite3
+
+The -dsn flag specifies the data source name for the database. This will vary
+depending on the type of database you're connecting to.
+
+mysql and postgresql both allow for the URI connection string, such as,
+
+    type://[user[:password]@][host]:[port][,...][/dbname][?param1=value1&...]
+
+where type would either be mysql or postgresql. The postgresql type also allows
+for the DSN string such as,
+
+    host=localhost port=5432 dbname=mydb connect_timeout=10
+
+sqlite3 however will accept a filepath, or the :memory: string, for example,
+
+    -dsn :memory:`,
+	Run: planCmd,
+}
+
+// printPlan prints each revision in the given plan along with its SQL.
+func printPlan(plan mgrt.Plan) {
+	for _, rev := range plan {
+		fmt.Println("revision", rev.Slug())
+		fmt.Println("Author:    ", rev.Author)
+		fmt.Println()
+
+		if rev.Comment != "" {
+			lines := strings.Split(rev.Comment, "\n")
+
+			for _, line := range lines {
+				fmt.Println("   ", line)
+			}
+			fmt.Println()
+		}
+
+		lines := strings.Split(rev.SQL, "\n")
+
+		for _, line := range lines {
+			fmt.Println("   ", line)
+		}
+		fmt.Println()
+	}
+}
+
+func planCmd(cmd *Command, args []string) {
+	info, err := os.Stat(revisionsDir)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "%s: no revisions to plan\n", cmd.Argv0)
+			os.Exit(1)
+		}
+
+		fmt.Fprintf(os.Stderr, "%s: failed to plan revisions: %s\n", cmd.Argv0, err)
+		os.Exit(1)
+	}
+
+	if !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "%s: %s is not a directory\n", cmd.Argv0, revisionsDir)
+		os.Exit(1)
+	}
+
+	var (
+		typ      string
+		dsn      string
+		category string
+		dbname   string
+		table    string
+	)
+
+	fs := flag.NewFlagSet(cmd.Argv0, flag.ExitOnError)
+	fs.StringVar(&typ, "type", "", "the database type one of postgresql, sqlite3")
+	fs.StringVar(&dsn, "dsn", "", "the dsn for the database to plan the revisions against")
+	fs.StringVar(&category, "c", "", "the category of revisions to plan")
+	fs.StringVar(&dbname, "db", "", "the database to connect to")
+	fs.StringVar(&table, "table", "", "the table the revision log is stored in")
+	fs.Parse(args[1:])
+
+	if dbname != "" {
+		it, err := getdbitem(dbname)
+
+		if err != nil {
+			if os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "%s: database %s does not exist\n", cmd.Argv0, dbname)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+
+		typ = it.Type
+		dsn = it.DSN
+
+		if table == "" {
+			table = it.Table
+		}
+	}
+
+	if typ == "" {
+		fmt.Fprintf(os.Stderr, "%s: database not specified\n", cmd.Argv0)
+		os.Exit(1)
+	}
+
+	if dsn == "" {
+		fmt.Fprintf(os.Stderr, "%s: database not specified\n", cmd.Argv0)
+		os.Exit(1)
+	}
+
+	revs := make([]*mgrt.Revision, 0)
+
+	for _, id := range fs.Args() {
+		rev, err := mgrt.OpenRevision(revisionPath(id))
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: failed to open revision %s: %s\n", cmd.Argv0, id, err)
+			os.Exit(1)
+		}
+		revs = append(revs, rev)
+	}
+
+	if len(revs) == 0 {
+		dir := revisionsDir
+
+		if category != "" {
+			dir = filepath.Join(revisionsDir, category)
+		}
+
+		revs, err = mgrt.LoadRevisions(dir)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+	}
+
+	db, err := mgrt.Open(typ, dsn, openOptions(table)...)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+		os.Exit(1)
+	}
+
+	defer db.Close()
+
+	plan, err := mgrt.PlanRevisions(db, revs...)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: failed to plan revisions: %s\n", cmd.Argv0, err)
+		os.Exit(1)
+	}
+	printPlan(plan)
+}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/andrewpillar/mgrt/v3"
+)
+
+var RollbackCmd = &Command{
+	Usage: "rollback [-n N | revision]",
+	Short: "rollback performed revisions",
+	Long: `Rollback will undo the revisions that have been performed against the given
+database by running the down SQL of each revision, and removing it from the
+revision log. If a revision is given, then only that revision will be rolled
+back, otherwise the -n flag specifies how many of the latest revisions to
+rollback, by default this is 1. The revisions are rolled back in reverse order.
+If any of the revisions do not have down SQL, then nothing is rolled back.
+
+The database to connect to is specified via the -type and -dsn flags, or via
+the -db flag if a database connection has been configured via the "mgrt db"
+command.
+
+The -table flag specifies the table the revision log is stored in, by default
+this is mgrt_revisions. This can be qualified with a schema, for example,
+
+    -table myschema.mgrt_revisions
+
+The -type flag specifies the type of database to connect to, it will be one of,
+
+    mysql
+    postgresql
+    sqlite3
+
+The -dsn flag specifies the data source name for the database. This will vary
+depending on the type of database you're connecting to.
+
+mysql and postgresql both allow for the URI connection string, such as,
+
+    type://[user[:password]@][host]:[port][,...][/dbname][?param1=value1&...]
+
+where type would either be mysql or postgresql. The postgresql type also allows
+for the DSN string such as,
+
+    host=localhost port=5432 dbname=mydb connect_timeout=10
+
+sqlite3 however will accept a filepath, or the :memory: string, for example,
+
+    -dsn :memory:`,
+	Run: rollbackCmd,
+}
+
+func rollbackCmd(cmd *Command, args []string) {
+	var (
+		typ     string
+		dsn     string
+		dbname  string
+		table   string
+		n       int
+		verbose bool
+	)
+
+	fs := flag.NewFlagSet(cmd.Argv0, flag.ExitOnError)
+	fs.StringVar(&typ, "type", "", "the database type one of postgresql, sqlite3")
+	fs.StringVar(&dsn, "dsn", "", "the dsn for the database to rollback the revisions in")
+	fs.StringVar(&dbname, "db", "", "the database to connect to")
+	fs.StringVar(&table, "table", "", "the table the revision log is stored in")
+	fs.IntVar(&n, "n", 1, "the number of revisions to rollback")
+	fs.BoolVar(&verbose, "v", false, "display information about the revisions rolled back")
+	fs.Parse(args[1:])
+
+	if n < 1 {
+		fmt.Fprintf(os.Stderr, "%s: invalid number of revisions %d\n", cmd.Argv0, n)
+		os.Exit(1)
+	}
+
+	if dbname != "" {
+		it, err := getdbitem(dbname)
+
+		if err != nil {
+			if os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "%s: database %s does not exist\n", cmd.Argv0, dbname)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+
+		typ = it.Type
+		dsn = it.DSN
+
+		if table == "" {
+			table = it.Table
+		}
+	}
+
+	if typ == "" {
+		fmt.Fprintf(os.Stderr, "%s: database not specified\n", cmd.Argv0)
+		os.Exit(1)
+	}
+
+	if dsn == "" {
+		fmt.Fprintf(os.Stderr, "%s: database not specified\n", cmd.Argv0)
+		os.Exit(1)
+	}
+
+	db, err := mgrt.Open(typ, dsn, openOptions(table)...)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+		os.Exit(1)
+	}
+
+	defer db.Close()
+
+	args = fs.Args()
+
+	var revs []*mgrt.Revision
+
+	if len(args) >= 1 {
+		rev, err := mgrt.GetRevision(db, args[0])
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: failed to get revision: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+		revs = append(revs, rev)
+	}
+
+	if len(revs) == 0 {
+		revs, err = mgrt.GetRevisions(db, n)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: failed to get revisions: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+	}
+
+	if len(revs) == 0 {
+		fmt.Fprintf(os.Stderr, "%s: no revisions to rollback\n", cmd.Argv0)
+		os.Exit(1)
+	}
+
+	if err := mgrt.RollbackRevisions(db, revs...); err != nil {
+		if errs, ok := err.(mgrt.Errors); ok {
+			for _, err := range errs {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+			}
+			os.Exit(1)
+		}
+
+		fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+		os.Exit(1)
+	}
+
+	if verbose {
+		for _, rev := range revs {
+			fmt.Println(rev.Slug(), rev.Title())
+		}
+	}
+}
@@ -1,10 +1,16 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/andrewpillar/mgrt/v3"
 )
@@ -19,6 +25,44 @@ connection has been configured via the "mgrt db" command.
 The -c flag specifies the category of revisions to run. If not given, then the
 default revisions will be run.
 
+Before any revisions are run, the revisions are verified to ensure they have not
+been changed since they were performed, see "mgrt help verify". If any of the
+revisions have changed, then nothing is run. The -force flag can be given to
+skip this verification.
+
+Revisions that are older than a revision already run in their category, which
+they do not depend on, are considered out of order, this typically happens when
+revisions made on separate branches are merged. If any of the revisions to run
+are out of order, then nothing is run. The -allow-out-of-order flag can be
+given to run them anyway.
+
+If a revision fails to run, then the remaining revisions are still run, except
+for those that depend on the revision that failed, which are reported as having
+failed too.
+
+The -v flag will display each revision as it is performed, along with how long
+it took to perform, and each revision that is skipped because it has already
+been performed.
+
+The -dry-run flag will display the revisions that would be run along with their
+SQL, without running them, see "mgrt help plan".
+
+The -timeout flag specifies how long the revisions have to run before they are
+cancelled, for example -timeout 5m. Sending an interrupt to the process will
+also cancel the revisions. A revision that is cancelled whilst being performed
+will be rolled back if it was being performed inside of a transaction.
+
+An exclusive lock is acquired on the database before any revisions are run, so
+that concurrent invocations of "mgrt run" against the same database cannot
+perform the same revisions. The -lock-timeout flag specifies how long to wait
+for the lock to be acquired, by default this will wait indefinitely. If a lock
+becomes stuck, then it can be cleared with "mgrt unlock".
+
+The -table flag specifies the table the revision log is stored in, by default
+this is mgrt_revisions. This can be qualified with a schema, for example,
+
+    -table myschema.mgrt_revisions
+
 The -type flag specifies the type of database to connect to, it will be one of,
 
     mysql
@@ -43,6 +87,28 @@ sqlite3 however will accept a filepath, or the :memory: string, for example,
 	Run: runCmd,
 }
 
+// progress is the mgrt.Observer that displays the revisions as they are
+// performed when -v is given. Errors are not displayed, since these are always
+// displayed by the run command. The progress is written to stderr if the
+// output format is structured, so as not to interfere with the summary.
+type progress struct {
+	w io.Writer
+}
+
+func (p progress) BeforePerform(_ context.Context, rev *mgrt.Revision) {
+	fmt.Fprintf(p.w, "performing %s %s\n", rev.Slug(), rev.Title())
+}
+
+func (p progress) AfterPerform(_ context.Context, rev *mgrt.Revision, d time.Duration) {
+	fmt.Fprintf(p.w, "performed %s in %s\n", rev.Slug(), d.Round(time.Millisecond))
+}
+
+func (progress) OnError(context.Context, *mgrt.Revision, time.Duration, error) {}
+
+func (p progress) OnSkip(_ context.Context, rev *mgrt.Revision, _ error) {
+	fmt.Fprintf(p.w, "skipped %s, already performed\n", rev.Slug())
+}
+
 func runCmd(cmd *Command, args []string) {
 	info, err := os.Stat(revisionsDir)
 
@@ -62,11 +128,17 @@ func runCmd(cmd *Command, args []string) {
 	}
 
 	var (
-		typ      string
-		dsn      string
-		category string
-		dbname   string
-		verbose  bool
+		typ       string
+		dsn       string
+		category  string
+		dbname    string
+		table     string
+		verbose   bool
+		force     bool
+		timeout   time.Duration
+		lockwait  time.Duration
+		dryrun    bool
+		unordered bool
 	)
 
 	fs := flag.NewFlagSet(cmd.Argv0, flag.ExitOnError)
@@ -74,7 +146,13 @@ func runCmd(cmd *Command, args []string) {
 	fs.StringVar(&dsn, "dsn", "", "the dsn for the database to run the revisions against")
 	fs.StringVar(&category, "c", "", "the category of revisions to run")
 	fs.StringVar(&dbname, "db", "", "the database to connect to")
+	fs.StringVar(&table, "table", "", "the table the revision log is stored in")
 	fs.BoolVar(&verbose, "v", false, "display information about the revisions performed")
+	fs.BoolVar(&dryrun, "dry-run", false, "display the revisions that would be run without running them")
+	fs.BoolVar(&force, "force", false, "run the revisions even if they have changed since being performed")
+	fs.BoolVar(&unordered, "allow-out-of-order", false, "run the revisions even if they are out of order")
+	fs.DurationVar(&timeout, "timeout", 0, "the amount of time to allow the revisions to run for")
+	fs.DurationVar(&lockwait, "lock-timeout", 0, "the amount of time to wait to acquire the lock on the database")
 	fs.Parse(args[1:])
 
 	if dbname != "" {
@@ -91,6 +169,10 @@ func runCmd(cmd *Command, args []string) {
 
 		typ = it.Type
 		dsn = it.DSN
+
+		if table == "" {
+			table = it.Table
+		}
 	}
 
 	if typ == "" {
@@ -130,7 +212,17 @@ func runCmd(cmd *Command, args []string) {
 		}
 	}
 
-	db, err := mgrt.Open(typ, dsn)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	db, err := mgrt.OpenContext(ctx, typ, dsn, openOptions(table)...)
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
@@ -139,23 +231,151 @@ func runCmd(cmd *Command, args []string) {
 
 	defer db.Close()
 
-	var c mgrt.Collection
+	if !force {
+		if err := mgrt.VerifyRevisionsContext(ctx, db, revs...); err != nil {
+			if errs, ok := err.(mgrt.Errors); ok {
+				for _, err := range errs {
+					fmt.Fprintf(os.Stderr, "%s\n", err)
+				}
+				fmt.Fprintf(os.Stderr, "%s: revisions changed since being performed, use -force to run anyway\n", cmd.Argv0)
+				os.Exit(1)
+			}
 
-	for _, rev := range revs {
-		c.Put(rev)
+			fmt.Fprintf(os.Stderr, "%s: failed to verify revisions: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+	}
+
+	if !unordered {
+		if err := mgrt.CheckOrderContext(ctx, db, revs...); err != nil {
+			if errs, ok := err.(mgrt.Errors); ok {
+				for _, err := range errs {
+					fmt.Fprintf(os.Stderr, "%s\n", err)
+				}
+				fmt.Fprintf(os.Stderr, "%s: revisions out of order, use -allow-out-of-order to run anyway\n", cmd.Argv0)
+				os.Exit(1)
+			}
+
+			fmt.Fprintf(os.Stderr, "%s: failed to check revision order: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+	}
+
+	db.AllowOutOfOrder = unordered
+
+	if dryrun {
+		plan, err := mgrt.PlanRevisionsContext(ctx, db, revs...)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: failed to plan revisions: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+
+		printPlan(plan)
+		return
+	}
+
+	db.LockTimeout = lockwait
+
+	if verbose {
+		p := progress{w: os.Stdout}
+
+		if structured() {
+			p.w = os.Stderr
+		}
+		db.Observer = p
+	}
+
+	l, err := mgrt.AcquireLockContext(ctx, db)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: failed to acquire lock: %s\n", cmd.Argv0, err)
+		os.Exit(1)
+	}
+
+	revs, err = mgrt.SortRevisionsContext(ctx, db, revs...)
+
+	if err != nil {
+		l.Release()
+		fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+		os.Exit(1)
 	}
 
 	code := 0
 
-	for _, rev := range c.Slice() {
-		if err := rev.Perform(db); err != nil {
-			fmt.Fprintf(os.Stderr, "%s\n", err)
+	summary := runSummary{
+		Applied: make([]string, 0, len(revs)),
+		Skipped: make([]string, 0),
+		Failed:  make([]runFailure, 0),
+	}
+
+	// failed is the set of revisions that failed to be performed, so the
+	// revisions that depend on them are not performed either.
+	failed := make(map[string]struct{})
+
+	for _, rev := range revs {
+		var depErr error
+
+		for _, dep := range rev.Depends {
+			if _, ok := failed[dep]; ok {
+				depErr = &mgrt.RevisionError{
+					ID:  rev.Slug(),
+					Err: errors.New("dependency " + dep + " failed"),
+				}
+				break
+			}
+		}
+
+		if depErr != nil {
+			failed[rev.Slug()] = struct{}{}
+
+			summary.Failed = append(summary.Failed, runFailure{
+				Slug:  rev.Slug(),
+				Error: depErr.Error(),
+			})
+
+			if !structured() {
+				fmt.Fprintf(os.Stderr, "%s\n", depErr)
+			}
 			code = 1
 			continue
 		}
 
-		if verbose {
-			fmt.Println(rev.ID, rev.Title())
+		if err := rev.PerformContext(ctx, db); err != nil {
+			if errors.Is(err, mgrt.ErrPerformed) {
+				summary.Skipped = append(summary.Skipped, rev.Slug())
+			} else {
+				failed[rev.Slug()] = struct{}{}
+
+				summary.Failed = append(summary.Failed, runFailure{
+					Slug:  rev.Slug(),
+					Error: err.Error(),
+				})
+			}
+
+			if !structured() {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+			}
+			code = 1
+
+			if ctx.Err() != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, ctx.Err())
+				break
+			}
+			continue
+		}
+		summary.Applied = append(summary.Applied, rev.Slug())
+	}
+
+	if err := l.Release(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: failed to release lock: %s\n", cmd.Argv0, err)
+		code = 1
+	}
+
+	if structured() {
+		if err := printObject(summary); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+			code = 1
 		}
 	}
 
@@ -4,8 +4,6 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"strings"
-	"time"
 
 	"github.com/andrewpillar/mgrt/v3"
 )
@@ -18,6 +16,23 @@ specified, then the latest revision will be shown, if any. The database to conne
 specified via the -type and -dsn flags, or via the -db flag if a database connection has
 been configured via the "mgrt db" command.
 
+The -format flag specifies the format to display the revision in. This can be
+one of text, json, or ndjson, see "mgrt help", or a template that is rendered
+via text/template for each revision, for example,
+
+    -format '{{.Slug}} {{.Author}} {{date "rfc3339" .PerformedAt}}'
+
+the following helper functions are available to the template,
+
+    title   the title of the given comment
+    date    format the given time in the given layout, such as rfc3339
+    indent  indent each line of the given string by the given spaces
+
+The -table flag specifies the table the revision log is stored in, by default
+this is mgrt_revisions. This can be qualified with a schema, for example,
+
+    -table myschema.mgrt_revisions
+
 The -type flag specifies the type of database to connect to, it will be one of,
 
     mysql
@@ -47,14 +62,25 @@ func showCmd(cmd *Command, args []string) {
 		typ    string
 		dsn    string
 		dbname string
+		table  string
+		format string
 	)
 
 	fs := flag.NewFlagSet(cmd.Argv0, flag.ExitOnError)
 	fs.StringVar(&typ, "type", "", "the database type one of postgresql, sqlite3")
 	fs.StringVar(&dsn, "dsn", "", "the dsn for the database to run the revisions against")
 	fs.StringVar(&dbname, "db", "", "the database to connect to")
+	fs.StringVar(&table, "table", "", "the table the revision log is stored in")
+	fs.StringVar(&format, "format", "", "the format to display the revision in")
 	fs.Parse(args[1:])
 
+	tmpl, err := outputTemplate(format, showTemplate)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: invalid format: %s\n", cmd.Argv0, err)
+		os.Exit(1)
+	}
+
 	if dbname != "" {
 		it, err := getdbitem(dbname)
 
@@ -69,6 +95,10 @@ func showCmd(cmd *Command, args []string) {
 
 		typ = it.Type
 		dsn = it.DSN
+
+		if table == "" {
+			table = it.Table
+		}
 	}
 
 	if typ == "" {
@@ -81,7 +111,7 @@ func showCmd(cmd *Command, args []string) {
 		os.Exit(1)
 	}
 
-	db, err := mgrt.Open(typ, dsn)
+	db, err := mgrt.Open(typ, dsn, openOptions(table)...)
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
@@ -113,22 +143,16 @@ func showCmd(cmd *Command, args []string) {
 		rev = revs[0]
 	}
 
-	fmt.Println("revision", rev.ID)
-	fmt.Println("Author:    ", rev.Author)
-	fmt.Println("Performed: ", rev.PerformedAt.Format(time.ANSIC))
-	fmt.Println()
-
-	lines := strings.Split(rev.Comment, "\n")
-
-	for _, line := range lines {
-		fmt.Println("   ", line)
+	if structured() {
+		if err := printObject(newRevisionObject(rev)); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+		return
 	}
-	fmt.Println()
-
-	lines = strings.Split(rev.SQL, "\n")
 
-	for _, line := range lines {
-		fmt.Println("   ", line)
+	if err := render(tmpl, rev); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+		os.Exit(1)
 	}
-	fmt.Println()
 }
@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/andrewpillar/mgrt/v3"
+)
+
+var StatusCmd = &Command{
+	Usage: "status",
+	Short: "show the status of the local revisions in the database",
+	Long: `Status will compare the local revisions against the revisions that have been
+performed against the given database. Each revision will be displayed as either
+applied, pending, or missing, grouped by category. A revision is missing when it
+has been performed against the database, but does not exist locally. If there
+are any pending revisions, then status will exit with a non-zero exit code.
+
+The -c flag specifies the category of revisions to show the status of. If not
+given, then all categories will be shown.
+
+The database to connect to is specified via the -type and -dsn flags, or via
+the -db flag if a database connection has been configured via the "mgrt db"
+command.
+
+The -table flag specifies the table the revision log is stored in, by default
+this is mgrt_revisions. This can be qualified with a schema, for example,
+
+    -table myschema.mgrt_revisions
+
+The -type flag specifies the type of database to connect to, it will be one of,
+
+    mysql
+    postgresql
+    sqlite3
+
+The -dsn flag specifies the data source name for the database. This will vary
+depending on the type of database you're connecting to.
+
+mysql and postgresql both allow for the URI connection string, such as,
+
+    type://[user[:password]@][host]:[port][,...][/dbname][?param1=value1&...]
+
+where type would either be mysql or postgresql. The postgresql type also allows
+for the DSN string such as,
+
+    host=localhost port=5432 dbname=mydb connect_timeout=10
+
+sqlite3 however will accept a filepath, or the :memory: string, for example,
+
+    -dsn :memory:`,
+	Run: statusCmd,
+}
+
+func statusCmd(cmd *Command, args []string) {
+	var (
+		typ      string
+		dsn      string
+		category string
+		dbname   string
+		table    string
+	)
+
+	fs := flag.NewFlagSet(cmd.Argv0, flag.ExitOnError)
+	fs.StringVar(&typ, "type", "", "the database type one of postgresql, sqlite3")
+	fs.StringVar(&dsn, "dsn", "", "the dsn for the database to check the revisions against")
+	fs.StringVar(&category, "c", "", "the category of revisions to show the status of")
+	fs.StringVar(&dbname, "db", "", "the database to connect to")
+	fs.StringVar(&table, "table", "", "the table the revision log is stored in")
+	fs.Parse(args[1:])
+
+	if dbname != "" {
+		it, err := getdbitem(dbname)
+
+		if err != nil {
+			if os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "%s: database %s does not exist\n", cmd.Argv0, dbname)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+
+		typ = it.Type
+		dsn = it.DSN
+
+		if table == "" {
+			table = it.Table
+		}
+	}
+
+	if typ == "" {
+		fmt.Fprintf(os.Stderr, "%s: database not specified\n", cmd.Argv0)
+		os.Exit(1)
+	}
+
+	if dsn == "" {
+		fmt.Fprintf(os.Stderr, "%s: database not specified\n", cmd.Argv0)
+		os.Exit(1)
+	}
+
+	revs := make([]*mgrt.Revision, 0)
+
+	info, err := os.Stat(revisionsDir)
+
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "%s: failed to load revisions: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+	}
+
+	if err == nil {
+		if !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "%s: %s is not a directory\n", cmd.Argv0, revisionsDir)
+			os.Exit(1)
+		}
+
+		revs, err = mgrt.LoadRevisions(revisionsDir)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: failed to load revisions: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+	}
+
+	db, err := mgrt.Open(typ, dsn, openOptions(table)...)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+		os.Exit(1)
+	}
+
+	defer db.Close()
+
+	report, err := mgrt.Status(db, revs...)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: failed to get status: %s\n", cmd.Argv0, err)
+		os.Exit(1)
+	}
+
+	if structured() {
+		objs := make([]interface{}, 0)
+		code := 0
+
+		for _, c := range report.Categories {
+			if category != "" && c != category {
+				continue
+			}
+
+			for _, st := range report.Revisions[c] {
+				if st.State == mgrt.StatePending {
+					code = 1
+				}
+
+				objs = append(objs, statusObject{
+					revisionObject: newRevisionObject(st.Revision),
+					State:          st.State.String(),
+				})
+			}
+		}
+
+		if err := printObjects(objs); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Argv0, err)
+			os.Exit(1)
+		}
+
+		if code != 0 {
+			os.Exit(code)
+		}
+		return
+	}
+
+	pad := 0
+
+	for _, statuses := range report.Revisions {
+		for _, st := range statuses {
+			if l := len(st.Revision.Slug()); l > pad {
+				pad = l
+			}
+		}
+	}
+
+	code := 0
+	first := true
+
+	for _, c := range report.Categories {
+		if category != "" && c != category {
+			continue
+		}
+
+		if !first {
+			fmt.Println()
+		}
+		first = false
+
+		if c == "" {
+			fmt.Println("revisions:")
+		} else {
+			fmt.Printf("revisions in %s:\n", c)
+		}
+
+		for _, st := range report.Revisions[c] {
+			if st.State == mgrt.StatePending {
+				code = 1
+			}
+
+			if title := st.Revision.Title(); title != "" {
+				fmt.Printf("    %-7s %-*s - %s\n", st.State, pad, st.Revision.Slug(), title)
+				continue
+			}
+			fmt.Printf("    %-7s %s\n", st.State, st.Revision.Slug())
+		}
+	}
+
+	if code != 0 {
+		os.Exit(code)
+	}
+}
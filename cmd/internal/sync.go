@@ -18,6 +18,11 @@ locally. The database to connect to is specified via the -type and -dsn flags,
 or via the -db flag if a database connection has been configured via the "mgrt db"
 command.
 
+The -table flag specifies the table the revision log is stored in, by default
+this is mgrt_revisions. This can be qualified with a schema, for example,
+
+    -table myschema.mgrt_revisions
+
 The -type flag specifies the type of database to connect to, it will be one of,
 
     mysql
@@ -49,12 +54,14 @@ func syncCmd(cmd *Command, args []string) {
 		typ    string
 		dsn    string
 		dbname string
+		table  string
 	)
 
 	fs := flag.NewFlagSet(cmd.Argv0+" "+argv0, flag.ExitOnError)
 	fs.StringVar(&typ, "type", "", "the database type one of postgresql, sqlite3")
 	fs.StringVar(&dsn, "dsn", "", "the dsn for the database to run the revisions against")
 	fs.StringVar(&dbname, "db", "", "the database to connect to")
+	fs.StringVar(&table, "table", "", "the table the revision log is stored in")
 	fs.Parse(args[1:])
 
 	if dbname != "" {
@@ -71,6 +78,10 @@ func syncCmd(cmd *Command, args []string) {
 
 		typ = it.Type
 		dsn = it.DSN
+
+		if table == "" {
+			table = it.Table
+		}
 	}
 
 	if typ == "" {
@@ -83,7 +94,7 @@ func syncCmd(cmd *Command, args []string) {
 		os.Exit(1)
 	}
 
-	db, err := mgrt.Open(typ, dsn)
+	db, err := mgrt.Open(typ, dsn, openOptions(table)...)
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s %s: %s\n", cmd.Argv0, argv0, err)
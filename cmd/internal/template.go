@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/andrewpillar/mgrt/v3"
+)
+
+var (
+	// funcs are the helper functions available to the templates given via the
+	// -format flag of a command.
+	funcs = template.FuncMap{
+		"title":  title,
+		"date":   date,
+		"indent": indent,
+	}
+
+	// logTemplate is the template used for displaying each revision in the
+	// revision log.
+	logTemplate = `revision {{.Slug}}
+Author:     {{.Author}}
+Performed:  {{date "ansic" .PerformedAt}}
+{{- if .Baselined}}
+Baselined:  yes
+{{- else if .Marked}}
+Marked:     yes
+{{- else}}
+Duration:   {{.Duration}}
+{{- with .PerformedBy}}
+By:         {{.}}
+{{- end}}
+{{- with .Version}}
+Version:    {{.}}
+{{- end}}
+{{- end}}
+
+{{indent 4 .Comment}}
+`
+
+	// failureTemplate is the template used for displaying each failed attempt
+	// at performing a revision.
+	failureTemplate = `failure {{.Slug}}
+Failed:     {{date "ansic" .FailedAt}}
+Duration:   {{.Duration}}
+{{- with .PerformedBy}}
+By:         {{.}}
+{{- end}}
+{{- with .Version}}
+Version:    {{.}}
+{{- end}}
+
+{{indent 4 .Message}}
+`
+
+	// showTemplate is the template used for displaying a single revision along
+	// with its SQL.
+	showTemplate = `revision {{.ID}}
+Author:     {{.Author}}
+Performed:  {{date "ansic" .PerformedAt}}
+{{- if .Baselined}}
+Baselined:  yes
+{{- else if .Marked}}
+Marked:     yes
+{{- else}}
+Duration:   {{.Duration}}
+{{- with .PerformedBy}}
+By:         {{.}}
+{{- end}}
+{{- with .Version}}
+Version:    {{.}}
+{{- end}}
+{{- end}}
+
+{{indent 4 .Comment}}
+
+{{indent 4 .SQL}}
+`
+
+	// layouts are the names of the time layouts that can be given to date.
+	layouts = map[string]string{
+		"ansic":    time.ANSIC,
+		"rfc822":   time.RFC822,
+		"rfc1123":  time.RFC1123,
+		"rfc3339":  time.RFC3339,
+		"kitchen":  time.Kitchen,
+		"datetime": "2006-01-02 15:04:05",
+		"date":     "2006-01-02",
+	}
+)
+
+// title returns the title of the given comment, this is truncated the same as
+// the title of a revision.
+func title(s string) string {
+	rev := mgrt.Revision{Comment: s}
+	return rev.Title()
+}
+
+// date formats the given time in the given layout. The layout can either be
+// the name of a layout, such as rfc3339, or a layout as understood by
+// time.Format.
+func date(layout string, t time.Time) string {
+	if l, ok := layouts[layout]; ok {
+		layout = l
+	}
+	return t.Format(layout)
+}
+
+// indent indents each line in the given string by n spaces.
+func indent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+
+	lines := strings.Split(s, "\n")
+
+	for i, line := range lines {
+		lines[i] = pad + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// outputTemplate returns the template for the given -format flag of a command.
+// If the flag is one of text, json, or ndjson, then the output format is set
+// to it, and the given default template is returned. Otherwise, the flag is
+// parsed as the template, and the output format is set to text, so a template
+// given to a command takes precedence over the global -format flag. If the
+// default template is empty, and the flag is not a template, then nil is
+// returned.
+func outputTemplate(format, def string) (*template.Template, error) {
+	switch format {
+	case "":
+	case "text", "json", "ndjson":
+		Format = format
+	default:
+		Format = "text"
+		def = format
+	}
+
+	if def == "" {
+		return nil, nil
+	}
+	return template.New("format").Funcs(funcs).Parse(def)
+}
+
+// render executes the given template against the given data to stdout,
+// followed by a newline.
+func render(tmpl *template.Template, data interface{}) error {
+	if err := tmpl.Execute(os.Stdout, data); err != nil {
+		return err
+	}
+
+	_, err := os.Stdout.WriteString("\n")
+	return err
+}
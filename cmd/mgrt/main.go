@@ -11,36 +11,80 @@ import (
 var Build string
 
 func run(args []string) error {
+	internal.Build = Build
+
 	cmds := &internal.CommandSet{
 		Argv0: args[0],
 		Long: `mgrt is a simple migration tool.
 
 Usage:
 
-    mgrt [-version] <command> [arguments]
+    mgrt [-version] [-format format] [-log-format format] [-log-level level] <command> [arguments]
+
+The -format flag specifies the format of the output of the ls, log, show,
+status, and run commands, one of text, json, or ndjson, by default this is
+text. With json, a listing is written as a single array of objects, and with
+ndjson each object is written on its own line. The run command writes a summary
+of the revisions that were applied, skipped, and failed.
+
+The -log-format flag enables logging of the revisions performed to stderr in
+the given format, one of text or json. The -log-level flag sets the level of
+the logs, one of debug, info, warn, or error, by default this is info. Giving
+either flag will enable logging.
 `,
 	}
 
 	cmds.Add("add", internal.AddCmd)
+	cmds.Add("baseline", internal.BaselineCmd)
 	cmds.Add("cat", internal.CatCmd)
 	cmds.Add("db", internal.DBCmd(cmds.Argv0))
+	cmds.Add("forget", internal.ForgetCmd)
 	cmds.Add("log", internal.LogCmd)
 	cmds.Add("ls", internal.LsCmd)
+	cmds.Add("mark", internal.MarkCmd)
+	cmds.Add("plan", internal.PlanCmd)
+	cmds.Add("rollback", internal.RollbackCmd)
 	cmds.Add("run", internal.RunCmd)
 	cmds.Add("show", internal.ShowCmd)
+	cmds.Add("status", internal.StatusCmd)
 	cmds.Add("sync", internal.SyncCmd)
+	cmds.Add("unlock", internal.UnlockCmd)
+	cmds.Add("verify", internal.VerifyCmd)
 	cmds.Add("help", internal.HelpCmd(cmds))
 
-	var version bool
+	var (
+		version   bool
+		format    string
+		logformat string
+		loglevel  string
+	)
 
 	fs := flag.NewFlagSet(args[0], flag.ExitOnError)
 	fs.BoolVar(&version, "version", false, "display version information and exit")
+	fs.StringVar(&format, "format", "text", "the format of the output, one of text, json, ndjson")
+	fs.StringVar(&logformat, "log-format", "", "the format of the logs, one of text, json")
+	fs.StringVar(&loglevel, "log-level", "", "the level of the logs, one of debug, info, warn, error")
 	fs.Parse(args[1:])
 
 	if version {
 		fmt.Println(Build)
 		return nil
 	}
+
+	if err := internal.ValidFormat(format); err != nil {
+		return err
+	}
+
+	internal.Format = format
+
+	if logformat != "" || loglevel != "" {
+		l, err := internal.NewLogger(os.Stderr, logformat, loglevel)
+
+		if err != nil {
+			return err
+		}
+		internal.Logger = l
+	}
 	return cmds.Parse(fs.Args())
 }
 
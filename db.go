@@ -1,145 +1,397 @@
 package mgrt
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"strconv"
+	"log/slog"
+	"os"
+	"os/user"
 	"strings"
 	"sync"
-
-	_ "github.com/go-sql-driver/mysql"
-	_ "github.com/jackc/pgx/v4/stdlib"
+	"time"
 )
 
 // DB is a thin abstraction over the *sql.DB struct from the stdlib.
 type DB struct {
 	*sql.DB
 
-	// Type is the type of database being connected to. This will be passed to
-	// sql.Open when the connection is being opened.
-	Type string
+	// Dialect is the SQL dialect of the database being connected to.
+	Dialect Dialect
+
+	// Table is the name of the table that the revision log is stored in. If
+	// empty, then mgrt_revisions is used.
+	Table string
+
+	// Schema is the schema that the revision log table is in. If empty, then
+	// the default schema for the database connection is used.
+	Schema string
+
+	// Version is the version of mgrt that is recorded in the revision log
+	// alongside each revision that is performed.
+	Version string
+
+	// PerformedBy is who is recorded in the revision log as having performed
+	// each revision. If empty, then this will be the user and host of the
+	// current process in the format of user@host.
+	PerformedBy string
+
+	// AllowOutOfOrder denotes whether revisions that are older than the newest
+	// revision performed in their category can be performed, see CheckOrder.
+	AllowOutOfOrder bool
+
+	// LockTimeout is how long to wait to acquire the lock on the database
+	// before performing revisions. If 0, then this will wait indefinitely.
+	LockTimeout time.Duration
 
-	// Init is the function to call to initialize the database for performing
-	// revisions.
-	Init func(*sql.DB) error
+	// Observer is notified of each revision that is performed against the
+	// database. If nil, then nothing is notified.
+	Observer Observer
 
-	// Parameterize is the function that is called to parameterize the query
-	// that will be executed against the database. This will make sure the
-	// correct SQL dialect is being used for the type of database.
-	Parameterize func(string) string
+	// Logger is used for logging the initialization of the database, and each
+	// revision that is performed against it. If nil, then nothing is logged.
+	Logger *slog.Logger
 }
 
+// Dialect is the interface that implements the SQL dialect of a type of
+// database. Dialects are registered via Register, and are used for creating
+// the revision log table, and building the queries that are executed against
+// it. Dialects for mysql, postgresql, and sqlite3 are registered by default.
+type Dialect interface {
+	// Driver returns the name of the driver that is passed to sql.Open.
+	Driver() string
+
+	// Placeholder returns the placeholder for the nth parameter in a query,
+	// starting from 1.
+	Placeholder(n int) string
+
+	// Quote quotes the given identifier, such as a column name, so that
+	// identifiers which are reserved words can be used.
+	Quote(ident string) string
+
+	// Init initializes the database for performing revisions. This should
+	// create the revision log table, and the failures table, if they do not
+	// exist.
+	Init(ctx context.Context, db *DB) error
+
+	// TableExists checks to see if the given table exists in the database.
+	// The table may be qualified with a schema.
+	TableExists(ctx context.Context, db *DB, table string) (bool, error)
+
+	// Transactional reports whether the database supports transactional DDL.
+	// If true, then each revision will be performed inside of a transaction
+	// along with the insertion of its log entry.
+	Transactional() bool
+
+	// Lock acquires an exclusive lock on the database for performing
+	// revisions. The lock should be held by the given connection. This should
+	// wait until the given timeout is reached for the lock to be acquired, or
+	// indefinitely if the timeout is 0, returning ErrLocked if the lock could
+	// not be acquired. A database that does not support locking should return
+	// nil.
+	Lock(ctx context.Context, db *DB, conn *sql.Conn, timeout time.Duration) error
+
+	// Unlock releases the lock that was acquired on the given connection via
+	// Lock.
+	Unlock(ctx context.Context, db *DB, conn *sql.Conn) error
+
+	// ClearLock clears the lock on the database regardless of what is holding
+	// it. This is used for clearing a lock that has become stuck.
+	ClearLock(ctx context.Context, db *DB) error
+}
+
+// Splitter is the interface that a Dialect can implement to split the SQL of a
+// revision into the individual statements to execute. If a Dialect does not
+// implement this, then the SQL of a revision is executed as a single
+// statement.
+type Splitter interface {
+	Split(sql string) []Statement
+}
+
+// Upgrader is the interface that a Dialect can implement to upgrade the
+// revision log table from the given version of its layout to the current
+// version. This is called after Init when the database is opened, if the
+// revision log table existed beforehand, and is not at the current version.
+// This should be idempotent, since an older revision log table will have no
+// version recorded.
+type Upgrader interface {
+	Upgrade(ctx context.Context, db *DB, version int) error
+}
+
+// Option is a function that configures a *DB when it is opened.
+type Option func(*DB)
+
 var (
-	dbMu sync.RWMutex
-	dbs  = make(map[string]*DB)
-
-	mysqlInit = `CREATE TABLE mgrt_revisions (
-	id           VARCHAR NOT NULL UNIQUE,
-	author       VARCHAR NOT NULL,
-	comment      TEXT NOT NULL,
-	sql          TEXT NOT NULL,
-	performed_at INT NOT NULL
-);`
+	dialectMu sync.RWMutex
+	dialects  = make(map[string]Dialect)
+
+	defaultTable = "mgrt_revisions"
 
-	postgresInit = `CREATE TABLE mgrt_revisions (
-	id           VARCHAR NOT NULL UNIQUE,
-	author       VARCHAR NOT NULL,
-	comment      TEXT NOT NULL,
-	sql          TEXT NOT NULL,
-	performed_at INT NOT NULL
+	// failuresInit creates the table used for recording the failed attempts
+	// at performing a revision.
+	failuresInit = `CREATE TABLE IF NOT EXISTS %s (
+	id           VARCHAR NOT NULL,
+	message      TEXT NOT NULL,
+	duration     BIGINT NOT NULL,
+	version      VARCHAR NOT NULL,
+	performed_by VARCHAR NOT NULL,
+	failed_at    INT NOT NULL
 );`
 )
 
-func init() {
-	Register("mysql", &DB{
-		Type:         "mysql",
-		Init:         initMysql,
-		Parameterize: parameterizeMysql,
-	})
-
-	Register("postgresql", &DB{
-		Type:         "pgx",
-		Init:         initPostgresql,
-		Parameterize: parameterizePostgresql,
-	})
+// quoteDouble quotes the given identifier in double quotes, as per the SQL
+// standard.
+func quoteDouble(ident string) string {
+	return `"` + strings.ReplaceAll(ident, `"`, `""`) + `"`
 }
 
-func initMysql(db *sql.DB) error {
-	if _, err := db.Exec(mysqlInit); err != nil {
-		if !strings.Contains(err.Error(), "already exists") {
-			return err
-		}
+// Register will register the given Dialect for the given database type. If
+// the given type is a duplicate, then this panics. If the given Dialect is nil,
+// then this panics.
+func Register(typ string, d Dialect) {
+	dialectMu.Lock()
+	defer dialectMu.Unlock()
+
+	if d == nil {
+		panic("mgrt: nil dialect registered")
 	}
-	return nil
-}
 
-func initPostgresql(db *sql.DB) error {
-	if _, err := db.Exec(postgresInit); err != nil {
-		if !strings.Contains(err.Error(), "already exists") {
-			return err
-		}
+	if _, ok := dialects[typ]; ok {
+		panic("mgrt: dialect already registered for " + typ)
 	}
-	return nil
+	dialects[typ] = d
 }
 
-func parameterizeMysql(s string) string { return s }
-
-func parameterizePostgresql(s string) string {
-	q := make([]byte, 0, len(s))
-	n := int64(0)
+// WithTable sets the name of the table that the revision log is stored in.
+func WithTable(table string) Option {
+	return func(db *DB) {
+		db.Table = table
+	}
+}
 
-	for i := strings.Index(s, "?"); i != -1; i = strings.Index(s, "?") {
-		n++
+// WithSchema sets the schema that the revision log table is in.
+func WithSchema(schema string) Option {
+	return func(db *DB) {
+		db.Schema = schema
+	}
+}
 
-		q = append(q, s[:i]...)
-		q = append(q, '$')
-		q = strconv.AppendInt(q, n, 10)
+// WithVersion sets the version of mgrt that is recorded in the revision log.
+func WithVersion(version string) Option {
+	return func(db *DB) {
+		db.Version = version
+	}
+}
 
-		s = s[i+1:]
+// WithLogger sets the Logger used for logging the revisions performed.
+func WithLogger(l *slog.Logger) Option {
+	return func(db *DB) {
+		db.Logger = l
 	}
-	return string(append(q, []byte(s)...))
 }
 
-// Register will register the given *DB for the given database type. If the
-// given type is a duplicate, then this panics. If the given *DB is nil, then
-// this panics.
-func Register(typ string, db *DB) {
-	dbMu.Lock()
-	defer dbMu.Unlock()
+// lookup returns a new *DB using the Dialect registered for the given
+// database type.
+func lookup(typ string) (*DB, error) {
+	dialectMu.RLock()
+	defer dialectMu.RUnlock()
 
-	if db == nil {
-		panic("mgrt: nil database registered")
-	}
+	d, ok := dialects[typ]
 
-	if _, ok := dbs[typ]; ok {
-		panic("mgrt: database already registered for " + typ)
+	if !ok {
+		return nil, errors.New("unknown database type " + typ)
 	}
-	dbs[typ] = db
+	return &DB{Dialect: d}, nil
 }
 
 // Open is a utility function that will call sql.Open with the given typ and
 // dsn. The database connection returned from this will then be passed to Init
-// for initializing the database.
-func Open(typ, dsn string) (*DB, error) {
-	dbMu.RLock()
-	defer dbMu.RUnlock()
+// for initializing the database. Each call to Open returns a new *DB, the
+// given options are applied to this before it is initialized.
+func Open(typ, dsn string, opts ...Option) (*DB, error) {
+	return OpenContext(context.Background(), typ, dsn, opts...)
+}
 
-	db, ok := dbs[typ]
+// OpenContext is a utility function that will call sql.Open with the given typ
+// and dsn. The database connection returned from this will then be passed to
+// Init along with the given context for initializing the database. Each call
+// to OpenContext returns a new *DB, the given options are applied to this
+// before it is initialized.
+func OpenContext(ctx context.Context, typ, dsn string, opts ...Option) (*DB, error) {
+	db, err := lookup(typ)
 
-	if !ok {
-		return nil, errors.New("unknown database type " + typ)
+	if err != nil {
+		return nil, err
 	}
 
-	sqldb, err := sql.Open(db.Type, dsn)
+	sqldb, err := sql.Open(db.Dialect.Driver(), dsn)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if err := db.Init(sqldb); err != nil {
+	if err := db.init(ctx, sqldb, opts); err != nil {
+		sqldb.Close()
 		return nil, err
 	}
+	return db, nil
+}
 
-	db.DB = sqldb
+// Wrap returns a new *DB for the given database type that wraps the given
+// pre-existing database connection. The database connection will be passed to
+// Init for initializing the database. The given options are applied to the
+// returned *DB before it is initialized.
+func Wrap(typ string, sqldb *sql.DB, opts ...Option) (*DB, error) {
+	return WrapContext(context.Background(), typ, sqldb, opts...)
+}
+
+// WrapContext returns a new *DB for the given database type that wraps the
+// given pre-existing database connection. The database connection will be
+// passed to Init along with the given context for initializing the database.
+// The given options are applied to the returned *DB before it is initialized.
+func WrapContext(ctx context.Context, typ string, sqldb *sql.DB, opts ...Option) (*DB, error) {
+	db, err := lookup(typ)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.init(ctx, sqldb, opts); err != nil {
+		return nil, err
+	}
 	return db, nil
 }
+
+// init applies the given options to the *DB and initializes it with the given
+// database connection. If the revision log table already existed, then it is
+// upgraded to the current version of its layout.
+func (db *DB) init(ctx context.Context, sqldb *sql.DB, opts []Option) error {
+	db.DB = sqldb
+
+	for _, opt := range opts {
+		opt(db)
+	}
+
+	exists, err := db.Dialect.TableExists(ctx, db, db.qualifiedName())
+
+	if err != nil {
+		return err
+	}
+
+	if err := db.Dialect.Init(ctx, db); err != nil {
+		return err
+	}
+
+	if err := db.upgrade(ctx, exists); err != nil {
+		return err
+	}
+
+	db.logAttrs(ctx, slog.LevelInfo, "database initialized",
+		slog.String("driver", db.Dialect.Driver()),
+		slog.String("table", db.qualifiedName()),
+		slog.Bool("created", !exists),
+	)
+	return nil
+}
+
+// logAttrs logs the given message and attributes at the given level via the
+// Logger, if one is set.
+func (db *DB) logAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if db.Logger == nil {
+		return
+	}
+	db.Logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// qualifiedName returns the unquoted name of the revision log table, qualified
+// with the schema if one is set. This is used for looking up the table, and
+// naming its lock, where the name is passed as a value rather than as an
+// identifier.
+func (db *DB) qualifiedName() string {
+	table := db.Table
+
+	if table == "" {
+		table = defaultTable
+	}
+
+	if db.Schema != "" {
+		return db.Schema + "." + table
+	}
+	return table
+}
+
+// quoteTable returns the name of the revision log table with the given suffix
+// appended, quoted via the Dialect, and qualified with the schema if one is
+// set. This allows tables and schemas that are reserved words, or that contain
+// characters such as -, to be used.
+func (db *DB) quoteTable(suffix string) string {
+	table := db.Table
+
+	if table == "" {
+		table = defaultTable
+	}
+
+	table = db.Dialect.Quote(table + suffix)
+
+	if db.Schema != "" {
+		return db.Dialect.Quote(db.Schema) + "." + table
+	}
+	return table
+}
+
+// tableName returns the quoted name of the revision log table, qualified with
+// the schema if one is set.
+func (db *DB) tableName() string { return db.quoteTable("") }
+
+// failuresTable returns the quoted name of the table that failed attempts at
+// performing a revision are recorded in, this is derived from the revision log
+// table.
+func (db *DB) failuresTable() string { return db.quoteTable("_failures") }
+
+// performer returns who is recorded as having performed a revision, see
+// PerformedBy.
+func (db *DB) performer() string {
+	if db.PerformedBy != "" {
+		return db.PerformedBy
+	}
+
+	name := "unknown"
+
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+
+	host, err := os.Hostname()
+
+	if err != nil {
+		host = "unknown"
+	}
+	return name + "@" + host
+}
+
+// columns quotes each of the columns in the given comma separated list via
+// the Dialect.
+func (db *DB) columns(cols string) string {
+	parts := strings.Split(cols, ",")
+
+	for i, part := range parts {
+		parts[i] = db.Dialect.Quote(strings.TrimSpace(part))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parameterize replaces each ? placeholder in the given query with the
+// placeholder of the Dialect. Placeholders that appear in string literals,
+// quoted identifiers, or comments are left as is.
+func (db *DB) parameterize(q string) string {
+	return replacePlaceholders(q, db.Dialect.Placeholder)
+}
+
+// split splits the given SQL into statements if the Dialect implements
+// Splitter, otherwise the SQL is returned as a single statement.
+func (db *DB) split(sql string) []Statement {
+	if sp, ok := db.Dialect.(Splitter); ok {
+		return sp.Split(sql)
+	}
+	return []Statement{{SQL: sql, Line: 1}}
+}
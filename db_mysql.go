@@ -0,0 +1,149 @@
+package mgrt
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// mysqlDialect is the Dialect for MySQL and MariaDB.
+type mysqlDialect struct{}
+
+var (
+	mysqlInit = `CREATE TABLE IF NOT EXISTS %s (
+	id             VARCHAR(255) NOT NULL UNIQUE,
+	author         VARCHAR(255) NOT NULL,
+	comment        TEXT NOT NULL,
+	` + "`sql`" + `          MEDIUMTEXT NOT NULL,
+	down           MEDIUMTEXT NOT NULL,
+	checksum       VARCHAR(64) NOT NULL,
+	performed_at   BIGINT NOT NULL,
+	baselined      BOOLEAN NOT NULL DEFAULT FALSE,
+	duration       BIGINT NOT NULL DEFAULT 0,
+	version        VARCHAR(255) NOT NULL DEFAULT '',
+	performed_by   VARCHAR(255) NOT NULL DEFAULT '',
+	no_transaction BOOLEAN NOT NULL DEFAULT FALSE,
+	depends        TEXT NOT NULL,
+	marked         BOOLEAN NOT NULL DEFAULT FALSE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+
+	mysqlFailuresInit = `CREATE TABLE IF NOT EXISTS %s (
+	id           VARCHAR(255) NOT NULL,
+	message      TEXT NOT NULL,
+	duration     BIGINT NOT NULL,
+	version      VARCHAR(255) NOT NULL,
+	performed_by VARCHAR(255) NOT NULL,
+	failed_at    BIGINT NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+
+	// mysqlColumns are the columns added to the revision log table since its
+	// original layout for MySQL, which does not allow defaults for TEXT
+	// columns.
+	mysqlColumns = []column{
+		{"down", "MEDIUMTEXT NOT NULL"},
+		{"checksum", "VARCHAR(64) NOT NULL DEFAULT ''"},
+		{"baselined", "BOOLEAN NOT NULL DEFAULT FALSE"},
+		{"duration", "BIGINT NOT NULL DEFAULT 0"},
+		{"version", "VARCHAR(255) NOT NULL DEFAULT ''"},
+		{"performed_by", "VARCHAR(255) NOT NULL DEFAULT ''"},
+		{"no_transaction", "BOOLEAN NOT NULL DEFAULT FALSE"},
+		{"depends", "TEXT NOT NULL"},
+		{"marked", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	}
+
+	mysqlSplitter = splitter{
+		backslash: true,
+		backtick:  true,
+		hash:      true,
+		delimiter: true,
+	}
+)
+
+func init() {
+	Register("mysql", mysqlDialect{})
+}
+
+func (mysqlDialect) Driver() string { return "mysql" }
+
+func (mysqlDialect) Placeholder(int) string { return "?" }
+
+func (mysqlDialect) Quote(ident string) string {
+	return "`" + strings.ReplaceAll(ident, "`", "``") + "`"
+}
+
+func (mysqlDialect) Transactional() bool { return false }
+
+func (mysqlDialect) Split(sql string) []Statement { return mysqlSplitter.split(sql) }
+
+func (mysqlDialect) Init(ctx context.Context, db *DB) error {
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(mysqlInit, db.tableName())); err != nil {
+		return err
+	}
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf(mysqlFailuresInit, db.failuresTable()))
+	return err
+}
+
+func (mysqlDialect) Upgrade(ctx context.Context, db *DB, _ int) error {
+	return addColumns(ctx, db, mysqlColumns)
+}
+
+// TableExists checks the information schema for the given table. If the table
+// is not qualified with a schema, then the current database is used.
+func (mysqlDialect) TableExists(ctx context.Context, db *DB, table string) (bool, error) {
+	var schema sql.NullString
+
+	if i := strings.LastIndex(table, "."); i > 0 {
+		schema.String, schema.Valid = table[:i], true
+		table = table[i+1:]
+	}
+
+	q := "SELECT COUNT(*) FROM information_schema.tables WHERE (table_schema = COALESCE(?, DATABASE()) AND table_name = ?)"
+
+	var n int64
+
+	if err := db.QueryRowContext(ctx, q, schema, table).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// mysqlLock returns the name of the lock acquired via GET_LOCK, this is
+// derived from the revision log table so each table has its own lock.
+func mysqlLock(db *DB) string { return "mgrt:" + db.qualifiedName() }
+
+func (mysqlDialect) Lock(ctx context.Context, db *DB, conn *sql.Conn, timeout time.Duration) error {
+	return pollLock(ctx, timeout, func() (bool, error) {
+		var ok sql.NullInt64
+
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", mysqlLock(db)).Scan(&ok); err != nil {
+			return false, err
+		}
+		return ok.Int64 == 1, nil
+	})
+}
+
+func (mysqlDialect) Unlock(ctx context.Context, db *DB, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "DO RELEASE_LOCK(?)", mysqlLock(db))
+	return err
+}
+
+func (mysqlDialect) ClearLock(ctx context.Context, db *DB) error {
+	var id sql.NullInt64
+
+	if err := db.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?)", mysqlLock(db)).Scan(&id); err != nil {
+		return err
+	}
+
+	if !id.Valid {
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx, "KILL "+strconv.FormatInt(id.Int64, 10))
+	return err
+}
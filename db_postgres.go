@@ -0,0 +1,127 @@
+package mgrt
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v4/stdlib"
+)
+
+// postgresDialect is the Dialect for PostgreSQL.
+type postgresDialect struct{}
+
+var (
+	postgresInit = `CREATE TABLE IF NOT EXISTS %s (
+	id             VARCHAR NOT NULL UNIQUE,
+	author         VARCHAR NOT NULL,
+	comment        TEXT NOT NULL,
+	sql            TEXT NOT NULL,
+	down           TEXT NOT NULL,
+	checksum       VARCHAR(64) NOT NULL,
+	performed_at   INT NOT NULL,
+	baselined      BOOLEAN NOT NULL DEFAULT FALSE,
+	duration       BIGINT NOT NULL DEFAULT 0,
+	version        VARCHAR NOT NULL DEFAULT '',
+	performed_by   VARCHAR NOT NULL DEFAULT '',
+	no_transaction BOOLEAN NOT NULL DEFAULT FALSE,
+	depends        TEXT NOT NULL DEFAULT '',
+	marked         BOOLEAN NOT NULL DEFAULT FALSE
+);`
+
+	postgresSplitter = splitter{
+		escape: true,
+		dollar: true,
+	}
+)
+
+func init() {
+	Register("postgresql", postgresDialect{})
+}
+
+func (postgresDialect) Driver() string { return "pgx" }
+
+func (postgresDialect) Placeholder(n int) string { return "$" + strconv.Itoa(n) }
+
+func (postgresDialect) Quote(ident string) string { return quoteDouble(ident) }
+
+func (postgresDialect) Transactional() bool { return true }
+
+func (postgresDialect) Split(sql string) []Statement { return postgresSplitter.split(sql) }
+
+func (postgresDialect) Init(ctx context.Context, db *DB) error {
+	if db.Schema != "" {
+		if _, err := db.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS "+quoteDouble(db.Schema)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(postgresInit, db.tableName())); err != nil {
+		return err
+	}
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf(failuresInit, db.failuresTable()))
+	return err
+}
+
+func (postgresDialect) Upgrade(ctx context.Context, db *DB, _ int) error {
+	return addColumns(ctx, db, columns)
+}
+
+// TableExists checks for the given table via to_regclass, which resolves the
+// table against the search path if it is not qualified with a schema. The
+// table and schema are quoted, since to_regclass parses the name it is given.
+func (postgresDialect) TableExists(ctx context.Context, db *DB, table string) (bool, error) {
+	if i := strings.LastIndex(table, "."); i > 0 {
+		table = quoteDouble(table[:i]) + "." + quoteDouble(table[i+1:])
+	} else {
+		table = quoteDouble(table)
+	}
+
+	var ok bool
+
+	if err := db.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&ok); err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+// postgresLock returns the key of the advisory lock acquired via
+// pg_try_advisory_lock, this is the FNV-1a hash of the revision log table so
+// each table has its own lock. The key is kept to 32 bits so it can be found in
+// the objid column of pg_locks.
+func postgresLock(db *DB) int64 {
+	h := fnv.New32a()
+	h.Write([]byte(db.qualifiedName()))
+	return int64(h.Sum32())
+}
+
+func (postgresDialect) Lock(ctx context.Context, db *DB, conn *sql.Conn, timeout time.Duration) error {
+	return pollLock(ctx, timeout, func() (bool, error) {
+		var ok bool
+
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", postgresLock(db)).Scan(&ok); err != nil {
+			return false, err
+		}
+		return ok, nil
+	})
+}
+
+func (postgresDialect) Unlock(ctx context.Context, db *DB, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", postgresLock(db))
+	return err
+}
+
+// ClearLock terminates the backend holding the advisory lock, since advisory
+// locks can only be released by the session that acquired them.
+func (postgresDialect) ClearLock(ctx context.Context, db *DB) error {
+	q := `SELECT pg_terminate_backend(pid) FROM pg_locks
+WHERE (locktype = 'advisory' AND classid = 0 AND objid::bigint = $1 AND objsubid = 1)`
+
+	_, err := db.ExecContext(ctx, q, postgresLock(db))
+	return err
+}
@@ -4,33 +4,130 @@
 package mgrt
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"strings"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
-var sqlite3Init = `CREATE TABLE mgrt_revisions (
-	id           VARCHAR NOT NULL,
-	author       VARCHAR NOT NULL,
-	comment      TEXT NOT NULL,
-	sql          TEXT NOT NULL,
-	performed_at INT NOT NULL
+// sqlite3Dialect is the Dialect for SQLite3.
+type sqlite3Dialect struct{}
+
+var (
+	sqlite3Init = `CREATE TABLE IF NOT EXISTS %s (
+	id             VARCHAR NOT NULL,
+	author         VARCHAR NOT NULL,
+	comment        TEXT NOT NULL,
+	sql            TEXT NOT NULL,
+	down           TEXT NOT NULL,
+	checksum       VARCHAR(64) NOT NULL,
+	performed_at   INT NOT NULL,
+	baselined      BOOLEAN NOT NULL DEFAULT FALSE,
+	duration       BIGINT NOT NULL DEFAULT 0,
+	version        VARCHAR NOT NULL DEFAULT '',
+	performed_by   VARCHAR NOT NULL DEFAULT '',
+	no_transaction BOOLEAN NOT NULL DEFAULT FALSE,
+	depends        TEXT NOT NULL DEFAULT '',
+	marked         BOOLEAN NOT NULL DEFAULT FALSE
+);`
+
+	// sqlite3LockInit creates the table used for locking the database, since
+	// SQLite3 has no advisory locks, the lock is acquired by inserting a row
+	// into this table.
+	sqlite3LockInit = `CREATE TABLE IF NOT EXISTS %s (
+	id        INT NOT NULL UNIQUE,
+	locked_at INT NOT NULL
 );`
 
+	sqlite3Splitter = splitter{
+		backtick: true,
+		triggers: true,
+	}
+)
+
 func init() {
-	Register("sqlite3", &DB{
-		Type:         "sqlite3",
-		Init:         initSqlite3,
-		Parameterize: func(s string) string { return s },
-	})
+	Register("sqlite3", sqlite3Dialect{})
 }
 
-func initSqlite3(db *sql.DB) error {
-	if _, err := db.Exec(sqlite3Init); err != nil {
-		if !strings.Contains(err.Error(), "already exists") {
-			return err
-		}
+func (sqlite3Dialect) Driver() string { return "sqlite3" }
+
+func (sqlite3Dialect) Placeholder(int) string { return "?" }
+
+func (sqlite3Dialect) Quote(ident string) string { return quoteDouble(ident) }
+
+func (sqlite3Dialect) Transactional() bool { return true }
+
+func (sqlite3Dialect) Split(sql string) []Statement { return sqlite3Splitter.split(sql) }
+
+// sqlite3LockTable returns the quoted name of the table used for locking, this
+// is derived from the revision log table so each table has its own lock.
+func sqlite3LockTable(db *DB) string { return db.quoteTable("_lock") }
+
+func (sqlite3Dialect) Init(ctx context.Context, db *DB) error {
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(sqlite3Init, db.tableName())); err != nil {
+		return err
 	}
-	return nil
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(failuresInit, db.failuresTable())); err != nil {
+		return err
+	}
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf(sqlite3LockInit, sqlite3LockTable(db)))
+	return err
+}
+
+func (sqlite3Dialect) Upgrade(ctx context.Context, db *DB, _ int) error {
+	return addColumns(ctx, db, columns)
+}
+
+// TableExists checks sqlite_master for the given table. If the table is
+// qualified with a schema, then the sqlite_master of that schema is checked.
+func (sqlite3Dialect) TableExists(ctx context.Context, db *DB, table string) (bool, error) {
+	master := "sqlite_master"
+
+	if i := strings.LastIndex(table, "."); i > 0 {
+		master = quoteDouble(table[:i]) + ".sqlite_master"
+		table = table[i+1:]
+	}
+
+	var n int64
+
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+master+" WHERE (type = 'table' AND name = ?)", table).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (sqlite3Dialect) Lock(ctx context.Context, db *DB, conn *sql.Conn, timeout time.Duration) error {
+	q := "INSERT INTO " + sqlite3LockTable(db) + " (id, locked_at) VALUES (1, ?)"
+
+	return pollLock(ctx, timeout, func() (bool, error) {
+		_, err := conn.ExecContext(ctx, q, time.Now().Unix())
+
+		if err != nil {
+			var sqliteErr sqlite3.Error
+
+			if errors.As(err, &sqliteErr) {
+				if sqliteErr.Code == sqlite3.ErrConstraint || sqliteErr.Code == sqlite3.ErrBusy {
+					return false, nil
+				}
+			}
+			return false, err
+		}
+		return true, nil
+	})
+}
+
+func (sqlite3Dialect) Unlock(ctx context.Context, db *DB, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM "+sqlite3LockTable(db)+" WHERE (id = 1)")
+	return err
+}
+
+func (sqlite3Dialect) ClearLock(ctx context.Context, db *DB) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM "+sqlite3LockTable(db)+" WHERE (id = 1)")
+	return err
 }
@@ -0,0 +1,356 @@
+package mgrt
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func Test_OpenConcurrent(t *testing.T) {
+	tests := []struct {
+		id  string
+		sql string
+	}{
+		{"20060102150405", "CREATE TABLE users ( id INT NOT NULL UNIQUE );"},
+		{"20060102150406", "CREATE TABLE posts ( id INT NOT NULL UNIQUE );"},
+	}
+
+	dbs := make([]*DB, len(tests))
+	errs := make([]error, len(tests))
+
+	var wg sync.WaitGroup
+
+	for i := range tests {
+		tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer os.Remove(tmp.Name())
+
+		wg.Add(1)
+
+		go func(i int, name string) {
+			defer wg.Done()
+
+			dbs[i], errs[i] = Open("sqlite3", name)
+		}(i, tmp.Name())
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("tests[%d] - %s\n", i, err)
+		}
+		defer dbs[i].Close()
+	}
+
+	if dbs[0] == dbs[1] || dbs[0].DB == dbs[1].DB {
+		t.Fatalf("expected separate databases to be opened\n")
+	}
+
+	for i, test := range tests {
+		rev := NewRevision("Andrew", "")
+		rev.ID = test.id
+		rev.SQL = test.sql
+
+		if err := rev.Perform(dbs[i]); err != nil {
+			t.Fatalf("tests[%d] - %s\n", i, err)
+		}
+	}
+
+	for i, test := range tests {
+		revs, err := GetRevisions(dbs[i], -1)
+
+		if err != nil {
+			t.Fatalf("tests[%d] - %s\n", i, err)
+		}
+
+		if len(revs) != 1 {
+			t.Fatalf("tests[%d] - unexpected revision count, expected=%d, got=%d\n", i, 1, len(revs))
+		}
+
+		if revs[0].ID != test.id {
+			t.Fatalf("tests[%d] - unexpected revision, expected=%q, got=%q\n", i, test.id, revs[0].ID)
+		}
+	}
+}
+
+func Test_Wrap(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	sqldb, err := sql.Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sqldb.Close()
+
+	db, err := Wrap("sqlite3", sqldb)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if db.DB != sqldb {
+		t.Fatalf("expected wrapped database to be used\n")
+	}
+
+	rev := NewRevision("Andrew", "")
+	rev.ID = "20060102150405"
+	rev.SQL = "CREATE TABLE users ( id INT NOT NULL UNIQUE );"
+
+	if err := rev.Perform(db); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Wrap("foo", sqldb); err == nil {
+		t.Fatalf("expected error for unknown database type\n")
+	}
+}
+
+func Test_OpenTable(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3", tmp.Name(), WithSchema("main"), WithTable("app_revisions"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	rev := NewRevision("Andrew", "")
+	rev.ID = "20060102150405"
+	rev.SQL = "CREATE TABLE users ( id INT NOT NULL UNIQUE );"
+
+	if err := PerformRevisions(db, rev); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int64
+
+	if err := db.QueryRow("SELECT COUNT(id) FROM app_revisions").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 1 {
+		t.Fatalf("unexpected revision count, expected=%d, got=%d\n", 1, count)
+	}
+
+	if _, err := db.Exec("SELECT id FROM mgrt_revisions"); err == nil {
+		t.Fatalf("expected mgrt_revisions table to not exist\n")
+	}
+
+	if _, err := GetRevision(db, rev.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_OpenTableReserved(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	// Opening twice ensures the existing table is found, and upgraded.
+	for i := 0; i < 2; i++ {
+		db, err := Open("sqlite3", tmp.Name(), WithSchema("main"), WithTable("order"))
+
+		if err != nil {
+			t.Fatalf("open %d - %s\n", i, err)
+		}
+
+		rev := NewRevision("Andrew", "")
+		rev.ID = "2006010215040" + strconv.Itoa(5+i)
+		rev.SQL = "CREATE TABLE users" + strconv.Itoa(i) + " ( id INT NOT NULL UNIQUE );"
+
+		if err := PerformRevisions(db, rev); err != nil {
+			t.Fatalf("open %d - %s\n", i, err)
+		}
+
+		revs, err := GetRevisions(db, -1)
+
+		if err != nil {
+			t.Fatalf("open %d - %s\n", i, err)
+		}
+
+		if len(revs) != i+1 {
+			t.Fatalf("open %d - unexpected revision count, expected=%d, got=%d\n", i, i+1, len(revs))
+		}
+		db.Close()
+	}
+}
+
+// numberedDialect is a Dialect that uses numbered placeholders, for testing
+// dialects registered outside of mgrt.
+type numberedDialect struct {
+	Dialect
+}
+
+func (numberedDialect) Placeholder(n int) string { return "?" + strconv.Itoa(n) }
+
+func Test_RegisterDialect(t *testing.T) {
+	sqlite3, err := lookup("sqlite3")
+
+	if err != nil {
+		t.Skip(err)
+	}
+
+	Register("sqlite3-numbered", numberedDialect{Dialect: sqlite3.Dialect})
+
+	// Remove the dialect afterwards, so the test can be run more than once.
+	t.Cleanup(func() {
+		dialectMu.Lock()
+		delete(dialects, "sqlite3-numbered")
+		dialectMu.Unlock()
+	})
+
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3-numbered", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	rev := NewRevision("Andrew", "")
+	rev.ID = "20060102150405"
+	rev.SQL = "CREATE TABLE users ( id INT NOT NULL UNIQUE );"
+
+	if err := rev.Perform(db); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := GetRevision(db, rev.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected duplicate dialect to panic\n")
+		}
+	}()
+
+	Register("sqlite3-numbered", numberedDialect{Dialect: sqlite3.Dialect})
+}
+
+func Test_Parameterize(t *testing.T) {
+	tests := []struct {
+		q        string
+		expected string
+	}{
+		{"SELECT id FROM t WHERE (id = ?)", "SELECT id FROM t WHERE (id = $1)"},
+		{"INSERT INTO t (a, b) VALUES (?, ?)", "INSERT INTO t (a, b) VALUES ($1, $2)"},
+		{"SELECT '?', \"?\" FROM t WHERE (id = ?)", "SELECT '?', \"?\" FROM t WHERE (id = $1)"},
+		{"SELECT 'it''s ?' -- ?\nFROM t /* ? */ WHERE (id = ?)", "SELECT 'it''s ?' -- ?\nFROM t /* ? */ WHERE (id = $1)"},
+	}
+
+	db := &DB{Dialect: postgresDialect{}}
+
+	for i, test := range tests {
+		if q := db.parameterize(test.q); q != test.expected {
+			t.Errorf("tests[%d] - unexpected query, expected=%q, got=%q\n", i, test.expected, q)
+		}
+	}
+}
+
+func Test_Logger(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	var buf bytes.Buffer
+
+	l := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	db, err := Open("sqlite3", tmp.Name(), WithLogger(l))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE users ( id INT NOT NULL UNIQUE )"); err != nil {
+		t.Fatal(err)
+	}
+
+	rev := NewRevisionCategory("users", "Andrew", "Add users")
+	rev.ID = "20060102150405"
+	rev.SQL = `INSERT INTO users (id) VALUES (1);
+INSERT INTO users (id) VALUES (2);`
+
+	if err := rev.Perform(db); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rev.Perform(db); !errors.Is(err, ErrPerformed) {
+		t.Fatalf("unexpected error, expected=%v, got=%v\n", ErrPerformed, err)
+	}
+
+	type entry struct {
+		Msg      string
+		Revision string
+		Category string
+		Rows     int64
+	}
+
+	expected := []entry{
+		{Msg: "database initialized"},
+		{Msg: "performing revision", Revision: "users/20060102150405", Category: "users"},
+		{Msg: "revision performed", Revision: "users/20060102150405", Category: "users", Rows: 2},
+		{Msg: "revision skipped", Revision: "users/20060102150405", Category: "users"},
+	}
+
+	dec := json.NewDecoder(&buf)
+
+	for i, e := range expected {
+		var got entry
+
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("entries[%d] - %s\n", i, err)
+		}
+
+		if got != e {
+			t.Errorf("entries[%d] - unexpected entry, expected=%+v, got=%+v\n", i, e, got)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package mgrt
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Failure is a failed attempt at performing a Revision that was recorded in
+// the database.
+type Failure struct {
+	ID          string        // ID is the ID of the Revision that failed.
+	Category    string        // Category of the Revision that failed.
+	Message     string        // Message is the error that caused the Revision to fail.
+	Duration    time.Duration // Duration is how long the attempt ran for before failing.
+	PerformedBy string        // PerformedBy is who attempted the Revision, in the format of user@host.
+	Version     string        // Version is the version of mgrt the Revision was attempted with.
+	FailedAt    time.Time     // FailedAt is when the Revision failed.
+}
+
+var failureColumns = "id, message, duration, version, performed_by, failed_at"
+
+// recordFailure records the failed attempt at performing the given Revision.
+// Any error that occurs whilst recording the failure is ignored, since the
+// original error is more important.
+func recordFailure(ctx context.Context, db *DB, r *Revision, err error, d time.Duration) {
+	q := db.parameterize("INSERT INTO " + db.failuresTable() + " (" + failureColumns + ") VALUES (?, ?, ?, ?, ?, ?)")
+
+	db.ExecContext(ctx, q, r.Slug(), err.Error(), d.Milliseconds(), db.Version, db.performer(), time.Now().Unix())
+}
+
+// Slug returns the slug of the ID of the Revision that failed, this will be in
+// the format of category/id if the Revision belongs to a category.
+func (f *Failure) Slug() string {
+	if f.Category != "" {
+		return f.Category + "/" + f.ID
+	}
+	return f.ID
+}
+
+// GetFailures returns a list of the failed attempts at performing revisions
+// against the given database. If n is <= 0 then all of the failures will be
+// retrieved, otherwise, only the given amount will be retrieved. The returned
+// failures will be ordered by when they failed descending.
+func GetFailures(db *DB, n int) ([]*Failure, error) {
+	return GetFailuresContext(context.Background(), db, n)
+}
+
+// GetFailuresContext returns a list of the failed attempts at performing
+// revisions against the given database using the given context. This behaves
+// the same as GetFailures.
+func GetFailuresContext(ctx context.Context, db *DB, n int) ([]*Failure, error) {
+	count := int64(n)
+
+	if n <= 0 {
+		q0 := "SELECT COUNT(id) FROM " + db.failuresTable()
+
+		if err := db.QueryRowContext(ctx, q0).Scan(&count); err != nil {
+			return nil, err
+		}
+	}
+
+	fails := make([]*Failure, 0, int(count))
+
+	q := "SELECT " + failureColumns + " FROM " + db.failuresTable() + " ORDER BY failed_at DESC LIMIT ?"
+
+	rows, err := db.QueryContext(ctx, db.parameterize(q), count)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			f          Failure
+			ms         int64
+			sec        int64
+			categoryid string
+		)
+
+		if err := rows.Scan(&categoryid, &f.Message, &ms, &f.Version, &f.PerformedBy, &sec); err != nil {
+			return nil, err
+		}
+
+		f.ID = categoryid
+
+		if i := strings.LastIndex(categoryid, "/"); i >= 0 {
+			f.Category = categoryid[:i]
+			f.ID = categoryid[i+1:]
+		}
+
+		f.Duration = time.Duration(ms) * time.Millisecond
+		f.FailedAt = time.Unix(sec, 0)
+
+		fails = append(fails, &f)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return fails, nil
+}
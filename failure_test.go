@@ -0,0 +1,77 @@
+package mgrt
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func Test_GetFailures(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3", tmp.Name(), WithVersion("v3.0.0"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	db.PerformedBy = "andrew@localhost"
+
+	rev := NewRevisionCategory("users", "Andrew", "Add users table")
+	rev.ID = "20060102150405"
+	rev.SQL = "CREATE TABLE users ( id INT NOT NULL UNIQUE );"
+
+	if err := rev.Perform(db); err != nil {
+		t.Fatal(err)
+	}
+
+	rev2, err := GetRevision(db, rev.Slug())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rev2.Version != "v3.0.0" {
+		t.Errorf("unexpected revision version, expected=%q, got=%q\n", "v3.0.0", rev2.Version)
+	}
+
+	if rev2.PerformedBy != "andrew@localhost" {
+		t.Errorf("unexpected revision performed by, expected=%q, got=%q\n", "andrew@localhost", rev2.PerformedBy)
+	}
+
+	fail := NewRevisionCategory("users", "Andrew", "Add posts table")
+	fail.ID = "20060102150406"
+	fail.SQL = "INSERT INTO posts (id) VALUES (1);"
+
+	if err := fail.Perform(db); err == nil {
+		t.Fatalf("expected revision %s to fail\n", fail.Slug())
+	}
+
+	fails, err := GetFailures(db, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fails) != 1 {
+		t.Fatalf("unexpected failure count, expected=%d, got=%d\n", 1, len(fails))
+	}
+
+	f := fails[0]
+
+	if f.Slug() != fail.Slug() {
+		t.Errorf("unexpected failure, expected=%q, got=%q\n", fail.Slug(), f.Slug())
+	}
+
+	if f.Message == "" || f.Version != "v3.0.0" || f.PerformedBy != "andrew@localhost" {
+		t.Errorf("unexpected failure, got=%+v\n", f)
+	}
+}
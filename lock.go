@@ -0,0 +1,114 @@
+package mgrt
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Lock is an exclusive lock that has been acquired on a database. This is used
+// to prevent multiple processes from performing revisions against the same
+// database at the same time.
+type Lock struct {
+	db   *DB
+	conn *sql.Conn
+}
+
+var (
+	// lockInterval is how often to try and acquire a lock when it is held
+	// elsewhere.
+	lockInterval = time.Second / 2
+
+	// ErrLocked is returned whenever a lock on the database could not be
+	// acquired within the lock timeout.
+	ErrLocked = errors.New("database locked")
+)
+
+// pollLock calls try until it reports the lock as acquired, the given timeout
+// is reached, or the given context is cancelled. If the timeout is reached then
+// ErrLocked is returned.
+func pollLock(ctx context.Context, timeout time.Duration, try func() (bool, error)) error {
+	var expired <-chan time.Time
+
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+
+		expired = t.C
+	}
+
+	tick := time.NewTicker(lockInterval)
+	defer tick.Stop()
+
+	for {
+		ok, err := try()
+
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-expired:
+			return ErrLocked
+		case <-tick.C:
+		}
+	}
+}
+
+// AcquireLock acquires an exclusive lock on the given database via its Dialect,
+// waiting for up to the LockTimeout of the database.
+func AcquireLock(db *DB) (*Lock, error) {
+	return AcquireLockContext(context.Background(), db)
+}
+
+// AcquireLockContext acquires an exclusive lock on the given database using the
+// given context. This behaves the same as AcquireLock.
+func AcquireLockContext(ctx context.Context, db *DB) (*Lock, error) {
+	conn, err := db.Conn(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Dialect.Lock(ctx, db, conn, db.LockTimeout); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &Lock{
+		db:   db,
+		conn: conn,
+	}, nil
+}
+
+// ClearLock clears the lock on the given database, regardless of what is
+// holding it. This should only be used to clear a lock that has become stuck,
+// for example when the process holding it was killed.
+func ClearLock(db *DB) error {
+	return ClearLockContext(context.Background(), db)
+}
+
+// ClearLockContext clears the lock on the given database using the given
+// context. This behaves the same as ClearLock.
+func ClearLockContext(ctx context.Context, db *DB) error {
+	return db.Dialect.ClearLock(ctx, db)
+}
+
+// Release releases the lock. The lock is released regardless of whether the
+// context used to acquire it has been cancelled.
+func (l *Lock) Release() error {
+	if l.conn == nil {
+		return nil
+	}
+
+	defer l.conn.Close()
+
+	return l.db.Dialect.Unlock(context.Background(), l.db, l.conn)
+}
@@ -0,0 +1,67 @@
+package mgrt
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_AcquireLock(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	db.LockTimeout = time.Millisecond * 100
+
+	l, err := AcquireLock(db)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AcquireLock(db); !errors.Is(err, ErrLocked) {
+		t.Fatalf("unexpected error, expected=%T, got=%T\n", ErrLocked, err)
+	}
+
+	rev := NewRevision("Andrew", "Add users table")
+	rev.ID = "20060102150405"
+	rev.SQL = "CREATE TABLE users ( id INT NOT NULL UNIQUE );"
+
+	if err := PerformRevisions(db, rev); !errors.Is(err, ErrLocked) {
+		t.Fatalf("unexpected error, expected=%T, got=%T\n", ErrLocked, err)
+	}
+
+	if err := l.Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := PerformRevisions(db, rev); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AcquireLock(db); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ClearLock(db); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AcquireLock(db); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,88 @@
+package mgrt
+
+import "context"
+
+// Mark records the given Revision in the revision log of the given database as
+// having been performed, without executing it. This is used for repairing the
+// revision log when a Revision was performed by hand. The Revision will have
+// Marked set when retrieved from the revision log. If the Revision has already
+// been performed, then ErrPerformed is returned.
+func Mark(db *DB, rev *Revision) error {
+	return MarkContext(context.Background(), db, rev)
+}
+
+// MarkContext records the given Revision in the revision log of the given
+// database using the given context. This behaves the same as Mark.
+func MarkContext(ctx context.Context, db *DB, rev *Revision) error {
+	l, err := AcquireLockContext(ctx, db)
+
+	if err != nil {
+		return err
+	}
+
+	defer l.Release()
+
+	if err := RevisionPerformedContext(ctx, db, rev); err != nil {
+		return err
+	}
+
+	var sum string
+
+	if rev.Func == nil {
+		sum = checksum(rev.SQL)
+	}
+
+	if err := rev.log(ctx, db, db.DB, sum, 0, false, true); err != nil {
+		return &RevisionError{
+			ID:  rev.Slug(),
+			Err: err,
+		}
+	}
+	return nil
+}
+
+// Forget removes the Revision with the given ID from the revision log of the
+// given database, without executing its down SQL. This is used for repairing
+// the revision log when a Revision was logged but not actually performed. If
+// the Revision is not in the revision log, then ErrNotFound is returned.
+func Forget(db *DB, id string) error {
+	return ForgetContext(context.Background(), db, id)
+}
+
+// ForgetContext removes the Revision with the given ID from the revision log
+// of the given database using the given context. This behaves the same as
+// Forget.
+func ForgetContext(ctx context.Context, db *DB, id string) error {
+	l, err := AcquireLockContext(ctx, db)
+
+	if err != nil {
+		return err
+	}
+
+	defer l.Release()
+
+	q := db.parameterize("DELETE FROM " + db.tableName() + " WHERE (id = ?)")
+
+	res, err := db.ExecContext(ctx, q, id)
+
+	if err != nil {
+		return &RevisionError{
+			ID:  id,
+			Err: err,
+		}
+	}
+
+	n, err := res.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return &RevisionError{
+			ID:  id,
+			Err: ErrNotFound,
+		}
+	}
+	return nil
+}
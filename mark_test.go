@@ -0,0 +1,65 @@
+package mgrt
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func Test_MarkForget(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	rev := NewRevisionCategory("users", "Andrew", "Add users table")
+	rev.ID = "20060102150405"
+	rev.SQL = "CREATE TABLE users ( id INT NOT NULL UNIQUE );"
+
+	if err := Mark(db, rev); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Mark(db, rev); !errors.Is(err, ErrPerformed) {
+		t.Fatalf("unexpected error, expected=%v, got=%v\n", ErrPerformed, err)
+	}
+
+	rev2, err := GetRevision(db, rev.Slug())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rev2.Marked || rev2.Baselined {
+		t.Errorf("expected revision %s to be marked, and not baselined\n", rev.Slug())
+	}
+
+	// The SQL of a marked revision should not have been executed.
+	if _, err := db.Exec("SELECT id FROM users"); err == nil {
+		t.Fatalf("expected table users to not exist\n")
+	}
+
+	if err := Forget(db, rev.Slug()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Forget(db, rev.Slug()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unexpected error, expected=%v, got=%v\n", ErrNotFound, err)
+	}
+
+	if err := rev.Perform(db); err != nil {
+		t.Fatal(err)
+	}
+}
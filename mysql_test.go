@@ -0,0 +1,145 @@
+package mgrt
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// openMysql opens the MySQL database specified via the MGRT_MYSQL_DSN
+// environment variable, skipping the test if it is not set. The revision log
+// is stored in a table unique to the test, which is dropped once the test is
+// done.
+func openMysql(t *testing.T) *DB {
+	dsn := os.Getenv("MGRT_MYSQL_DSN")
+
+	if dsn == "" {
+		t.Skip("MGRT_MYSQL_DSN not set")
+	}
+
+	table := "mgrt_test_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	db, err := Open("mysql", dsn, WithTable(table))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		for _, name := range []string{db.tableName(), db.failuresTable(), db.metaTable()} {
+			db.Exec("DROP TABLE IF EXISTS " + name)
+		}
+		db.Close()
+	})
+	return db
+}
+
+func Test_MysqlOpen(t *testing.T) {
+	db := openMysql(t)
+
+	// Opening again should leave the existing tables as they are.
+	db2, err := Open("mysql", os.Getenv("MGRT_MYSQL_DSN"), WithTable(db.Table))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	db2.Close()
+}
+
+func Test_MysqlPerformRollback(t *testing.T) {
+	db := openMysql(t)
+
+	users := db.Table + "_users"
+
+	rev := NewRevision("Andrew", "Add users table")
+	rev.ID = "20060102150405"
+	rev.SQL = "CREATE TABLE " + users + " ( id INT NOT NULL UNIQUE, name VARCHAR(255) NOT NULL );\nINSERT INTO " + users + " (id, name) VALUES (1, 'Zoë; the admin');"
+	rev.Down = "DROP TABLE " + users + ";"
+
+	if err := PerformRevisions(db, rev); err != nil {
+		t.Fatal(err)
+	}
+
+	var name string
+
+	if err := db.QueryRow("SELECT name FROM " + users + " WHERE id = 1").Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+
+	if name != "Zoë; the admin" {
+		t.Errorf("unexpected name, expected=%q, got=%q\n", "Zoë; the admin", name)
+	}
+
+	rev2, err := GetRevision(db, rev.ID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rev2.SQL != rev.SQL || rev2.Down != rev.Down {
+		t.Errorf("unexpected revision, expected=%q, got=%q\n", rev.String(), rev2.String())
+	}
+
+	if err := VerifyRevisions(db, rev); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RollbackRevisions(db, rev); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := GetRevision(db, rev.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unexpected error, expected=%v, got=%v\n", ErrNotFound, err)
+	}
+}
+
+func Test_MysqlFailure(t *testing.T) {
+	db := openMysql(t)
+
+	rev := NewRevision("Andrew", "Insert into missing table")
+	rev.ID = "20060102150405"
+	rev.SQL = "INSERT INTO " + db.Table + "_missing (id) VALUES (1);"
+
+	if err := rev.Perform(db); err == nil {
+		t.Fatalf("expected revision %s to fail\n", rev.ID)
+	}
+
+	fails, err := GetFailures(db, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fails) != 1 {
+		t.Fatalf("unexpected failure count, expected=%d, got=%d\n", 1, len(fails))
+	}
+}
+
+func Test_MysqlLock(t *testing.T) {
+	db := openMysql(t)
+
+	l, err := AcquireLock(db)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.LockTimeout = time.Second
+
+	if _, err := AcquireLock(db); !errors.Is(err, ErrLocked) {
+		t.Fatalf("unexpected error, expected=%v, got=%v\n", ErrLocked, err)
+	}
+
+	if err := l.Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = AcquireLock(db)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Release()
+}
@@ -0,0 +1,54 @@
+package mgrt
+
+import (
+	"context"
+	"time"
+)
+
+// Observer is the interface that is notified of each Revision that is performed
+// against a database. An Observer can be set on a *DB via the Observer field,
+// or via the WithObserver option, and is used for recording metrics, audit
+// events, or displaying progress.
+type Observer interface {
+	// BeforePerform is called before the given Revision is performed.
+	BeforePerform(ctx context.Context, rev *Revision)
+
+	// AfterPerform is called after the given Revision has been performed,
+	// with the amount of time it took to perform.
+	AfterPerform(ctx context.Context, rev *Revision, d time.Duration)
+
+	// OnError is called when the given Revision fails to be performed, with
+	// the amount of time spent performing it, and the error that caused it to
+	// fail.
+	OnError(ctx context.Context, rev *Revision, d time.Duration, err error)
+
+	// OnSkip is called when the given Revision is not performed because it
+	// has already been performed. The given error will be the *RevisionError
+	// wrapping ErrPerformed.
+	OnSkip(ctx context.Context, rev *Revision, err error)
+}
+
+// nopObserver is the Observer used when none is set on the *DB.
+type nopObserver struct{}
+
+// WithObserver sets the Observer that is notified of each Revision that is
+// performed.
+func WithObserver(o Observer) Option {
+	return func(db *DB) {
+		db.Observer = o
+	}
+}
+
+func (nopObserver) BeforePerform(context.Context, *Revision)                 {}
+func (nopObserver) AfterPerform(context.Context, *Revision, time.Duration)   {}
+func (nopObserver) OnError(context.Context, *Revision, time.Duration, error) {}
+func (nopObserver) OnSkip(context.Context, *Revision, error)                 {}
+
+// observer returns the Observer set on the *DB, or an Observer that does
+// nothing if none is set.
+func (db *DB) observer() Observer {
+	if db.Observer != nil {
+		return db.Observer
+	}
+	return nopObserver{}
+}
@@ -0,0 +1,88 @@
+package mgrt
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) BeforePerform(_ context.Context, rev *Revision) {
+	r.events = append(r.events, "before "+rev.Slug())
+}
+
+func (r *recorder) AfterPerform(_ context.Context, rev *Revision, _ time.Duration) {
+	r.events = append(r.events, "after "+rev.Slug())
+}
+
+func (r *recorder) OnError(_ context.Context, rev *Revision, _ time.Duration, err error) {
+	r.events = append(r.events, "error "+rev.Slug())
+}
+
+func (r *recorder) OnSkip(_ context.Context, rev *Revision, err error) {
+	if !errors.Is(err, ErrPerformed) {
+		r.events = append(r.events, "unexpected skip "+rev.Slug())
+		return
+	}
+	r.events = append(r.events, "skip "+rev.Slug())
+}
+
+func Test_Observer(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	var rec recorder
+
+	db, err := Open("sqlite3", tmp.Name(), WithObserver(&rec))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	rev1 := NewRevision("Andrew", "Add users table")
+	rev1.ID = "20060102150405"
+	rev1.SQL = "CREATE TABLE users ( id INT NOT NULL UNIQUE );"
+
+	rev2 := NewRevision("Andrew", "Add posts table")
+	rev2.ID = "20060102150406"
+	rev2.SQL = "CREATE TABLE posts ( id INT NOT NULL UNIQUE, );"
+
+	if err := rev1.Perform(db); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := PerformRevisions(db, rev1, rev2); err == nil {
+		t.Fatalf("expected revisions to fail\n")
+	}
+
+	expected := []string{
+		"before 20060102150405",
+		"after 20060102150405",
+		"skip 20060102150405",
+		"before 20060102150406",
+		"error 20060102150406",
+	}
+
+	if len(rec.events) != len(expected) {
+		t.Fatalf("unexpected events, expected=%v, got=%v\n", expected, rec.events)
+	}
+
+	for i, ev := range expected {
+		if rec.events[i] != ev {
+			t.Errorf("events[%d] - unexpected event, expected=%q, got=%q\n", i, ev, rec.events[i])
+		}
+	}
+}
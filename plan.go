@@ -0,0 +1,47 @@
+package mgrt
+
+import (
+	"context"
+	"errors"
+)
+
+// Plan is the list of revisions that would be performed against a database, in
+// the order they would be performed in.
+type Plan []*Revision
+
+// PlanRevisions returns the Plan for performing the given revisions against the
+// given database. The given revisions will be sorted into ascending order, with
+// each Revision coming after the revisions it depends on. Any revisions that
+// have already been performed, or that have no SQL or Func, will be omitted
+// from the Plan. Nothing is performed against the database.
+func PlanRevisions(db *DB, revs ...*Revision) (Plan, error) {
+	return PlanRevisionsContext(context.Background(), db, revs...)
+}
+
+// PlanRevisionsContext returns the Plan for performing the given revisions
+// against the given database using the given context. This behaves the same
+// as PlanRevisions.
+func PlanRevisionsContext(ctx context.Context, db *DB, revs ...*Revision) (Plan, error) {
+	sorted, err := SortRevisionsContext(ctx, db, revs...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	plan := make(Plan, 0, len(sorted))
+
+	for _, rev := range sorted {
+		if rev.SQL == "" && rev.Func == nil {
+			continue
+		}
+
+		if err := RevisionPerformedContext(ctx, db, rev); err != nil {
+			if errors.Is(err, ErrPerformed) {
+				continue
+			}
+			return nil, err
+		}
+		plan = append(plan, rev)
+	}
+	return plan, nil
+}
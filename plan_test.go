@@ -0,0 +1,79 @@
+package mgrt
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func Test_PlanRevisions(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tests := []struct {
+		id      string
+		sql     string
+		perform bool
+	}{
+		{"20060102150407", "ALTER TABLE users ADD COLUMN password VARCHAR NOT NULL;", false},
+		{"20060102150405", "CREATE TABLE users ( id INT NOT NULL UNIQUE );", true},
+		{"20060102150408", "", false},
+		{"20060102150406", "ALTER TABLE users ADD COLUMN username VARCHAR NOT NULL;", false},
+	}
+
+	revs := make([]*Revision, 0, len(tests))
+
+	for _, test := range tests {
+		rev := NewRevision("Andrew", "")
+		rev.ID = test.id
+		rev.SQL = test.sql
+
+		if test.perform {
+			if err := rev.Perform(db); err != nil {
+				t.Fatal(err)
+			}
+		}
+		revs = append(revs, rev)
+	}
+
+	plan, err := PlanRevisions(db, revs...)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"20060102150406", "20060102150407"}
+
+	if len(plan) != len(expected) {
+		t.Fatalf("unexpected plan length, expected=%d, got=%d\n", len(expected), len(plan))
+	}
+
+	for i, rev := range plan {
+		if rev.ID != expected[i] {
+			t.Errorf("plan[%d] - unexpected revision, expected=%q, got=%q\n", i, expected[i], rev.ID)
+		}
+	}
+
+	revs, err = GetRevisions(db, -1)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revs) != 1 {
+		t.Fatalf("unexpected revision count, expected=%d, got=%d\n", 1, len(revs))
+	}
+}
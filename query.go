@@ -0,0 +1,131 @@
+package mgrt
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Filter is used for filtering the revisions retrieved from the revision log
+// via QueryRevisions. The zero value of each field matches every Revision.
+type Filter struct {
+	Category string    // Category matches the revisions in the given category.
+	Author   string    // Author matches the revisions whose author contains the given string.
+	Since    time.Time // Since matches the revisions performed at or after the given time.
+	Until    time.Time // Until matches the revisions performed at or before the given time.
+	Grep     string    // Grep matches the revisions whose comment or SQL contains the given string.
+
+	// Reverse orders the revisions by their performance date ascending,
+	// instead of descending.
+	Reverse bool
+
+	// Limit is the maximum number of revisions to retrieve. If <= 0, then all
+	// of the matching revisions are retrieved.
+	Limit int
+}
+
+// likeEscaper escapes the wildcards in a pattern for LIKE, using ! as the
+// escape character, since \ is treated differently across databases.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// contains returns the LIKE pattern for matching the given string anywhere in
+// a value.
+func contains(s string) string {
+	return "%" + likeEscaper.Replace(strings.ToLower(s)) + "%"
+}
+
+// where returns the conditions of the WHERE clause for the Filter, along with
+// the arguments for the placeholders in the conditions. The string matching
+// conditions are case insensitive.
+func (f Filter) where(db *DB) (string, []interface{}) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+
+	if f.Category != "" {
+		// The category of a Revision is everything before the last / in its
+		// ID, so nested categories are excluded.
+		prefix := likeEscaper.Replace(f.Category) + "/"
+
+		conds = append(conds, "(id LIKE ? ESCAPE '!' AND id NOT LIKE ? ESCAPE '!')")
+		args = append(args, prefix+"%", prefix+"%/%")
+	}
+
+	if f.Author != "" {
+		conds = append(conds, "(LOWER(author) LIKE ? ESCAPE '!')")
+		args = append(args, contains(f.Author))
+	}
+
+	if !f.Since.IsZero() {
+		conds = append(conds, "(performed_at >= ?)")
+		args = append(args, f.Since.Unix())
+	}
+
+	if !f.Until.IsZero() {
+		conds = append(conds, "(performed_at <= ?)")
+		args = append(args, f.Until.Unix())
+	}
+
+	if f.Grep != "" {
+		conds = append(conds, "(LOWER("+db.Dialect.Quote("comment")+") LIKE ? ESCAPE '!' OR LOWER("+db.Dialect.Quote("sql")+") LIKE ? ESCAPE '!')")
+		args = append(args, contains(f.Grep), contains(f.Grep))
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// QueryRevisions returns the revisions that have been performed against the
+// given database that match the given Filter. The filtering is done by the
+// database. The returned revisions will be ordered by their performance date
+// descending, unless Reverse is set on the Filter.
+func QueryRevisions(db *DB, f Filter) ([]*Revision, error) {
+	return QueryRevisionsContext(context.Background(), db, f)
+}
+
+// QueryRevisionsContext returns the revisions that have been performed against
+// the given database that match the given Filter using the given context. This
+// behaves the same as QueryRevisions.
+func QueryRevisionsContext(ctx context.Context, db *DB, f Filter) ([]*Revision, error) {
+	where, args := f.where(db)
+
+	order := " ORDER BY performed_at DESC, id DESC"
+
+	if f.Reverse {
+		order = " ORDER BY performed_at ASC, id ASC"
+	}
+
+	q := "SELECT " + db.columns(revisionColumns) + " FROM " + db.tableName() + where + order
+
+	if f.Limit > 0 {
+		q += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+
+	rows, err := db.QueryContext(ctx, db.parameterize(q), args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	revs := make([]*Revision, 0)
+
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+
+		if err != nil {
+			return nil, err
+		}
+		revs = append(revs, rev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return revs, nil
+}
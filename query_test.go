@@ -0,0 +1,102 @@
+package mgrt
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_QueryRevisions(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	revs := []struct {
+		category    string
+		id          string
+		author      string
+		comment     string
+		sql         string
+		performedAt string
+	}{
+		{"", "20060102150405", "Andrew", "Add users table", "CREATE TABLE users ( id INT );", "2006-01-02"},
+		{"users", "20060102150406", "Andrew", "Add username to users", "ALTER TABLE users ADD COLUMN username VARCHAR;", "2006-01-03"},
+		{"users", "20060102150407", "Jane", "Add 100% unique index", "CREATE UNIQUE INDEX users_username_idx ON users (username);", "2006-01-04"},
+		{"users/audit", "20060102150408", "Jane", "Add audit table", "CREATE TABLE users_audit ( id INT );", "2006-01-05"},
+		{"posts", "20060102150409", "andrew", "Add posts table", "CREATE TABLE posts ( id INT );", "2006-01-06"},
+	}
+
+	for _, it := range revs {
+		rev := NewRevisionCategory(it.category, it.author, it.comment)
+		rev.ID = it.id
+		rev.SQL = it.sql
+
+		if err := rev.Perform(db); err != nil {
+			t.Fatal(err)
+		}
+
+		performedAt, err := time.Parse("2006-01-02", it.performedAt)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := db.Exec("UPDATE mgrt_revisions SET performed_at = ? WHERE (id = ?)", performedAt.Unix(), rev.Slug()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	date := func(s string) time.Time {
+		t, _ := time.Parse("2006-01-02", s)
+		return t
+	}
+
+	tests := []struct {
+		filter   Filter
+		expected []string
+	}{
+		{Filter{}, []string{"posts/20060102150409", "users/audit/20060102150408", "users/20060102150407", "users/20060102150406", "20060102150405"}},
+		{Filter{Limit: 2}, []string{"posts/20060102150409", "users/audit/20060102150408"}},
+		{Filter{Reverse: true, Limit: 2}, []string{"20060102150405", "users/20060102150406"}},
+		{Filter{Category: "users"}, []string{"users/20060102150407", "users/20060102150406"}},
+		{Filter{Category: "users/audit"}, []string{"users/audit/20060102150408"}},
+		{Filter{Author: "andrew"}, []string{"posts/20060102150409", "users/20060102150406", "20060102150405"}},
+		{Filter{Since: date("2006-01-04"), Until: date("2006-01-05")}, []string{"users/audit/20060102150408", "users/20060102150407"}},
+		{Filter{Grep: "USERNAME"}, []string{"users/20060102150407", "users/20060102150406"}},
+		{Filter{Grep: "100%"}, []string{"users/20060102150407"}},
+		{Filter{Grep: "_audit"}, []string{"users/audit/20060102150408"}},
+		{Filter{Category: "users", Author: "jane", Grep: "index"}, []string{"users/20060102150407"}},
+		{Filter{Category: "comments"}, []string{}},
+	}
+
+	for i, test := range tests {
+		revs, err := QueryRevisions(db, test.filter)
+
+		if err != nil {
+			t.Fatalf("tests[%d] - %s\n", i, err)
+		}
+
+		if len(revs) != len(test.expected) {
+			t.Fatalf("tests[%d] - unexpected revision count, expected=%d, got=%d\n", i, len(test.expected), len(revs))
+		}
+
+		for j, rev := range revs {
+			if rev.Slug() != test.expected[j] {
+				t.Errorf("tests[%d] - unexpected revision at %d, expected=%q, got=%q\n", i, j, test.expected[j], rev.Slug())
+			}
+		}
+	}
+}
@@ -5,13 +5,22 @@ package mgrt
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
 // node is a node in the binary tree of a Collection. This stores the val used
@@ -27,6 +36,18 @@ type node struct {
 // Errors is a collection of errors that occurred.
 type Errors []error
 
+// scanner is the interface that wraps the Scan method, this is implemented by
+// both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(...interface{}) error
+}
+
+// execer is the interface that wraps the ExecContext method, this is
+// implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+}
+
 // Revision is the type that represents what SQL code has been executed against
 // a database as a revision. Typically, this would be changes made to the
 // database schema itself.
@@ -36,13 +57,70 @@ type Revision struct {
 	Author      string    // Author is who authored the original Revision.
 	Comment     string    // Comment provides a short description for the Revision.
 	SQL         string    // SQL is the code that will be executed when the Revision is performed.
+	Down        string    // Down is the code that will be executed when the Revision is rolled back.
+	Checksum    string    // Checksum is the SHA-256 of the SQL that was executed when the Revision was performed.
 	PerformedAt time.Time // PerformedAt is when the Revision was executed.
+	Baselined   bool      // Baselined is whether the Revision was logged via Baseline without being executed.
+	Marked      bool      // Marked is whether the Revision was logged via Mark without being executed.
+
+	Duration    time.Duration // Duration is how long the Revision took to execute when it was performed.
+	PerformedBy string        // PerformedBy is who performed the Revision, in the format of user@host.
+	Version     string        // Version is the version of mgrt the Revision was performed with.
+
+	// NoTransaction disables performing the Revision inside of a transaction.
+	// This is set via the "Transaction: none" header, and should be used for
+	// statements that cannot be run inside of a transaction, such as
+	// CREATE INDEX CONCURRENTLY. This is recorded in the revision log, so a
+	// Revision retrieved from it is rolled back outside of a transaction too.
+	NoTransaction bool
+
+	// Depends is the list of revisions that must be performed before this
+	// Revision, referenced by their slug. This is set via the "Depends"
+	// header, and is respected when the Revision is sorted via Collection.
+	// This is recorded in the revision log, so revisions retrieved from it are
+	// rolled back in the order of their dependencies.
+	Depends []string
+
+	// Func is the Go function that will be called when the Revision is
+	// performed, this is used in place of the SQL of the Revision. The
+	// Revision will always be performed inside of a transaction, and is
+	// logged the same as a Revision of SQL.
+	Func RevisionFunc
+
+	line     int // line is the line in the revision file the SQL starts on.
+	downLine int // downLine is the line in the revision file the down SQL starts on.
 }
 
+// RevisionFunc is the function that is called to perform a Revision written in
+// Go. The given transaction is the one the Revision is being performed in.
+type RevisionFunc func(ctx context.Context, tx *sql.Tx) error
+
 // RevisionError represents an error that occurred with a revision.
 type RevisionError struct {
 	ID  string // ID is the ID of the revisions that errored.
 	Err error  // Err is the underlying error itself.
+
+	// RolledBack denotes whether the transaction the revision was being
+	// performed in was rolled back because of the error.
+	RolledBack bool
+
+	// Statement is the position of the statement in the revision that caused
+	// the error, starting from 1. This will be 0 if the error was not caused
+	// by a statement.
+	Statement int
+
+	// Line is the line the statement that caused the error starts on. This
+	// will be the line in the revision file if the revision was unmarshalled,
+	// otherwise it will be the line in the SQL of the revision.
+	Line int
+}
+
+// statementError is an error that occurred when executing a statement in a
+// Revision.
+type statementError struct {
+	index int
+	line  int
+	err   error
 }
 
 // Collection stores revisions in a binary tree. This ensures that when they are
@@ -54,8 +132,17 @@ type Collection struct {
 }
 
 var (
+	revMu      sync.RWMutex
+	registered = make(map[string]*Revision)
+
 	revisionIdFormat = "20060102150405"
 
+	// downMarker is the comment that separates the SQL of a Revision from the
+	// SQL that undoes it.
+	downMarker = "-- mgrt:down"
+
+	revisionColumns = "id, author, comment, sql, down, checksum, performed_at, baselined, duration, version, performed_by, no_transaction, depends, marked"
+
 	// ErrInvalid is returned whenever an invalid Revision ID is encountered. A
 	// Revision ID is considered invalid when the time layout 20060102150405
 	// cannot be used for parse the ID.
@@ -66,6 +153,26 @@ var (
 	ErrPerformed = errors.New("revision already performed")
 
 	ErrNotFound = errors.New("revision not found")
+
+	// ErrIrreversible is returned whenever a Revision without any down SQL is
+	// rolled back.
+	ErrIrreversible = errors.New("revision cannot be rolled back")
+
+	// ErrChecksum is returned whenever the SQL of a Revision differs from the
+	// SQL that was executed when the Revision was performed.
+	ErrChecksum = errors.New("revision checksum mismatch")
+
+	// ErrMissingDependency is returned whenever a Revision depends on a
+	// Revision that cannot be found.
+	ErrMissingDependency = errors.New("revision dependency missing")
+
+	// ErrDependencyCycle is returned whenever the dependencies of a Revision
+	// lead back to itself.
+	ErrDependencyCycle = errors.New("revision dependency cycle")
+
+	// ErrOutOfOrder is returned whenever a Revision that has not been
+	// performed is older than the newest Revision performed in its category.
+	ErrOutOfOrder = errors.New("revision out of order")
 )
 
 func insertNode(n **node, val int64, r *Revision) {
@@ -101,18 +208,78 @@ func NewRevisionCategory(category, author, comment string) *Revision {
 	return rev
 }
 
+// NewRevisionFunc creates a new Revision with the given ID, author, and comment
+// that calls the given function when performed. The ID may be prefixed with
+// the category of the Revision, for example "users/20060102150405".
+func NewRevisionFunc(id, author, comment string, fn RevisionFunc) *Revision {
+	rev := &Revision{
+		ID:      id,
+		Author:  author,
+		Comment: comment,
+		Func:    fn,
+	}
+
+	if i := strings.LastIndex(id, "/"); i >= 0 {
+		rev.ID = id[i+1:]
+		rev.Category = id[:i]
+	}
+	return rev
+}
+
+// RegisterRevision will register the given Revision so it can be retrieved
+// via RegisteredRevisions. This is typically called from an init function to
+// register a Revision created via NewRevisionFunc. If the given Revision is
+// nil, has an invalid ID, or is a duplicate, then this panics.
+func RegisterRevision(rev *Revision) {
+	revMu.Lock()
+	defer revMu.Unlock()
+
+	if rev == nil {
+		panic("mgrt: nil revision registered")
+	}
+
+	if _, err := time.Parse(revisionIdFormat, rev.ID); err != nil {
+		panic("mgrt: invalid revision registered " + rev.Slug())
+	}
+
+	if _, ok := registered[rev.Slug()]; ok {
+		panic("mgrt: revision already registered " + rev.Slug())
+	}
+	registered[rev.Slug()] = rev
+}
+
+// RegisteredRevisions returns all of the revisions that have been registered
+// via RegisterRevision in ascending order.
+func RegisteredRevisions() []*Revision {
+	revMu.RLock()
+	defer revMu.RUnlock()
+
+	var c Collection
+
+	for _, rev := range registered {
+		c.Put(rev)
+	}
+	return c.Slice()
+}
+
 // RevisionPerformed checks to see if the given Revision has been performed
 // against the given database.
 func RevisionPerformed(db *DB, rev *Revision) error {
+	return RevisionPerformedContext(context.Background(), db, rev)
+}
+
+// RevisionPerformedContext checks to see if the given Revision has been
+// performed against the given database using the given context.
+func RevisionPerformedContext(ctx context.Context, db *DB, rev *Revision) error {
 	var count int64
 
 	if _, err := time.Parse(revisionIdFormat, rev.ID); err != nil {
 		return ErrInvalid
 	}
 
-	q := db.Parameterize("SELECT COUNT(id) FROM mgrt_revisions WHERE (id = ?)")
+	q := db.parameterize("SELECT COUNT(id) FROM " + db.tableName() + " WHERE (id = ?)")
 
-	if err := db.QueryRow(q, rev.Slug()).Scan(&count); err != nil {
+	if err := db.QueryRowContext(ctx, q, rev.Slug()).Scan(&count); err != nil {
 		return &RevisionError{
 			ID:  rev.Slug(),
 			Err: err,
@@ -128,26 +295,17 @@ func RevisionPerformed(db *DB, rev *Revision) error {
 	return nil
 }
 
-// GetRevision get's the Revision with the given ID.
-func GetRevision(db *DB, id string) (*Revision, error) {
+// scanRevision scans the columns in revisionColumns into a Revision.
+func scanRevision(sc scanner) (*Revision, error) {
 	var (
-		rev Revision
-		sec int64
+		rev        Revision
+		sec        int64
+		ms         int64
+		categoryid string
+		depends    string
 	)
 
-	q := "SELECT id, author, comment, sql, performed_at FROM mgrt_revisions WHERE (id = ?)"
-
-	row := db.QueryRow(db.Parameterize(q), id)
-
-	var categoryid string
-
-	if err := row.Scan(&categoryid, &rev.Author, &rev.Comment, &rev.SQL, &sec); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, &RevisionError{
-				ID:  categoryid,
-				Err: ErrNotFound,
-			}
-		}
+	if err := sc.Scan(&categoryid, &rev.Author, &rev.Comment, &rev.SQL, &rev.Down, &rev.Checksum, &sec, &rev.Baselined, &ms, &rev.Version, &rev.PerformedBy, &rev.NoTransaction, &depends, &rev.Marked); err != nil {
 		return nil, err
 	}
 
@@ -159,91 +317,327 @@ func GetRevision(db *DB, id string) (*Revision, error) {
 	rev.Category = strings.Join(parts[:end], "/")
 
 	rev.PerformedAt = time.Unix(sec, 0)
+	rev.Duration = time.Duration(ms) * time.Millisecond
+
+	if depends != "" {
+		rev.Depends = strings.Split(depends, ",")
+	}
 	return &rev, nil
 }
 
+// GetRevision get's the Revision with the given ID.
+func GetRevision(db *DB, id string) (*Revision, error) {
+	return GetRevisionContext(context.Background(), db, id)
+}
+
+// GetRevisionContext get's the Revision with the given ID using the given
+// context.
+func GetRevisionContext(ctx context.Context, db *DB, id string) (*Revision, error) {
+	q := "SELECT " + db.columns(revisionColumns) + " FROM " + db.tableName() + " WHERE (id = ?)"
+
+	rev, err := scanRevision(db.QueryRowContext(ctx, db.parameterize(q), id))
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &RevisionError{
+				ID:  id,
+				Err: ErrNotFound,
+			}
+		}
+		return nil, err
+	}
+	return rev, nil
+}
+
 // GetRevisions returns a list of all the revisions that have been performed
 // against the given database. If n is <= 0 then all of the revisions will be
 // retrieved, otherwise, only the given amount will be retrieved. The returned
 // revisions will be ordered by their performance date descending.
 func GetRevisions(db *DB, n int) ([]*Revision, error) {
-	count := int64(n)
+	return GetRevisionsContext(context.Background(), db, n)
+}
 
-	if n <= 0 {
-		q0 := "SELECT COUNT(id) FROM mgrt_revisions"
+// GetRevisionsContext returns a list of all the revisions that have been
+// performed against the given database using the given context. This behaves
+// the same as GetRevisions.
+func GetRevisionsContext(ctx context.Context, db *DB, n int) ([]*Revision, error) {
+	return QueryRevisionsContext(ctx, db, Filter{Limit: n})
+}
+
+// PerformRevisions will perform the given revisions against the given database.
+// The given revisions will be sorted into ascending order first before they
+// are performed, with each Revision coming after the revisions it depends on.
+// A dependency that is not in the given revisions must have already been
+// performed, otherwise ErrMissingDependency is returned. Unless AllowOutOfOrder
+// is set on the database, the given revisions are checked via CheckOrder, and
+// nothing is performed if any are out of order. If any of the given revisions
+// have already been performed then the Errors type will be returned containing
+// *RevisionError for each revision that was already performed. An exclusive
+// lock is acquired on the database via AcquireLock before any of the revisions
+// are performed. Each revision is performed via Perform, so the Observer set on
+// the database is notified of each revision.
+func PerformRevisions(db *DB, revs0 ...*Revision) error {
+	return PerformRevisionsContext(context.Background(), db, revs0...)
+}
 
-		if err := db.QueryRow(q0).Scan(&count); err != nil {
-			return nil, err
+// PerformRevisionsContext will perform the given revisions against the given
+// database using the given context. This behaves the same as
+// PerformRevisions.
+func PerformRevisionsContext(ctx context.Context, db *DB, revs0 ...*Revision) error {
+	l, err := AcquireLockContext(ctx, db)
+
+	if err != nil {
+		return err
+	}
+
+	defer l.Release()
+
+	revs, err := SortRevisionsContext(ctx, db, revs0...)
+
+	if err != nil {
+		return err
+	}
+
+	if !db.AllowOutOfOrder {
+		if err := CheckOrderContext(ctx, db, revs...); err != nil {
+			return err
+		}
+	}
+
+	errs := Errors(make([]error, 0, len(revs0)))
+
+	for _, rev := range revs {
+		if err := rev.PerformContext(ctx, db); err != nil {
+			if errors.Is(err, ErrPerformed) {
+				errs = append(errs, err)
+				continue
+			}
+			return err
 		}
 	}
+	return errs.err()
+}
+
+// SortRevisions sorts the given revisions into ascending order, with each
+// Revision coming after the revisions it depends on. This behaves the same as
+// Collection.Sort, only a dependency that is not in the given revisions is
+// satisfied if it has been performed against the given database.
+func SortRevisions(db *DB, revs ...*Revision) ([]*Revision, error) {
+	return SortRevisionsContext(context.Background(), db, revs...)
+}
+
+// SortRevisionsContext sorts the given revisions using the given context.
+// This behaves the same as SortRevisions.
+func SortRevisionsContext(ctx context.Context, db *DB, revs ...*Revision) ([]*Revision, error) {
+	var c Collection
+
+	for _, rev := range revs {
+		c.Put(rev)
+	}
 
-	revs := make([]*Revision, 0, int(count))
+	return c.sort(func(slug string) (bool, error) {
+		if _, err := GetRevisionContext(ctx, db, slug); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	})
+}
 
-	q := "SELECT id, author, comment, sql, performed_at FROM mgrt_revisions ORDER BY performed_at DESC LIMIT ?"
+// CheckOrder checks the given revisions against the revisions that have been
+// performed in the given database to find any that would be performed out of
+// order. A Revision is out of order if it has not been performed, and its ID
+// is older than a Revision performed in the same category that it does not
+// depend on. If any of the given revisions are out of order, then the Errors
+// type will be returned containing a *RevisionError for each revision,
+// wrapping ErrOutOfOrder.
+func CheckOrder(db *DB, revs ...*Revision) error {
+	return CheckOrderContext(context.Background(), db, revs...)
+}
 
-	rows, err := db.Query(db.Parameterize(q), count)
+// CheckOrderContext checks the given revisions for any that would be
+// performed out of order using the given context. This behaves the same as
+// CheckOrder.
+func CheckOrderContext(ctx context.Context, db *DB, revs ...*Revision) error {
+	rows, err := db.QueryContext(ctx, "SELECT id FROM "+db.tableName())
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	defer rows.Close()
 
+	performed := make(map[string]struct{})
+
+	// ids are the IDs of the performed revisions in each category.
+	ids := make(map[string][]string)
+
 	for rows.Next() {
-		var (
-			rev        Revision
-			sec        int64
-			categoryid string
-		)
+		var slug string
 
-		err = rows.Scan(&categoryid, &rev.Author, &rev.Comment, &rev.SQL, &sec)
+		if err := rows.Scan(&slug); err != nil {
+			return err
+		}
 
-		if err != nil {
-			return nil, err
+		performed[slug] = struct{}{}
+
+		category, id := "", slug
+
+		if i := strings.LastIndex(slug, "/"); i >= 0 {
+			category, id = slug[:i], slug[i+1:]
 		}
+		ids[category] = append(ids[category], id)
+	}
 
-		parts := strings.Split(categoryid, "/")
+	if err := rows.Err(); err != nil {
+		return err
+	}
 
-		end := len(parts) - 1
+	errs := Errors(make([]error, 0, len(revs)))
 
-		rev.ID = parts[end]
-		rev.Category = strings.Join(parts[:end], "/")
+	for _, rev := range revs {
+		if _, ok := performed[rev.Slug()]; ok {
+			continue
+		}
 
-		rev.PerformedAt = time.Unix(sec, 0)
-		revs = append(revs, &rev)
+		if rev.SQL == "" && rev.Func == nil {
+			continue
+		}
+
+		deps := make(map[string]struct{}, len(rev.Depends))
+
+		for _, dep := range rev.Depends {
+			deps[dep] = struct{}{}
+		}
+
+		for _, id := range ids[rev.Category] {
+			slug := id
+
+			if rev.Category != "" {
+				slug = rev.Category + "/" + id
+			}
+
+			if _, ok := deps[slug]; ok {
+				continue
+			}
+
+			// IDs are timestamps of a fixed layout, so they can be compared
+			// lexically.
+			if rev.ID < id {
+				errs = append(errs, &RevisionError{
+					ID:  rev.Slug(),
+					Err: ErrOutOfOrder,
+				})
+				break
+			}
+		}
 	}
+	return errs.err()
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
+// VerifyRevisions will verify the given revisions against the revisions that
+// have been performed in the given database. A Revision is verified by
+// comparing the checksum of its SQL against the checksum that was logged when
+// it was performed. Revisions that have not been performed are ignored. If any
+// of the given revisions fail verification, then the Errors type will be
+// returned containing a *RevisionError for each revision, wrapping
+// ErrChecksum.
+func VerifyRevisions(db *DB, revs ...*Revision) error {
+	return VerifyRevisionsContext(context.Background(), db, revs...)
+}
+
+// VerifyRevisionsContext will verify the given revisions against the
+// revisions that have been performed in the given database using the given
+// context. This behaves the same as VerifyRevisions.
+func VerifyRevisionsContext(ctx context.Context, db *DB, revs ...*Revision) error {
+	errs := Errors(make([]error, 0, len(revs)))
+
+	q := db.parameterize("SELECT checksum FROM " + db.tableName() + " WHERE (id = ?)")
+
+	for _, rev := range revs {
+		var sum string
+
+		if err := db.QueryRowContext(ctx, q, rev.Slug()).Scan(&sum); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return &RevisionError{
+				ID:  rev.Slug(),
+				Err: err,
+			}
+		}
+
+		if sum != "" && sum != checksum(rev.SQL) {
+			errs = append(errs, &RevisionError{
+				ID:  rev.Slug(),
+				Err: ErrChecksum,
+			})
+		}
 	}
-	return revs, nil
+	return errs.err()
 }
 
-// PerformRevisions will perform the given revisions against the given database.
-// The given revisions will be sorted into ascending order first before they
-// are performed. If any of the given revisions have already been performed then
-// the Errors type will be returned containing *RevisionError for each revision
-// that was already performed.
-func PerformRevisions(db *DB, revs0 ...*Revision) error {
+// RollbackRevisions will rollback the given revisions against the given
+// database. The given revisions will be sorted into descending order first
+// before they are rolled back, with each Revision coming before the revisions
+// it depends on. If any of the given revisions cannot be rolled back, then
+// none of them are, and the Errors type will be returned containing a
+// *RevisionError for each revision that has no down SQL. An exclusive lock is
+// acquired on the database via AcquireLock before any of the revisions are
+// rolled back.
+func RollbackRevisions(db *DB, revs0 ...*Revision) error {
+	return RollbackRevisionsContext(context.Background(), db, revs0...)
+}
+
+// RollbackRevisionsContext will rollback the given revisions against the
+// given database using the given context. This behaves the same as
+// RollbackRevisions.
+func RollbackRevisionsContext(ctx context.Context, db *DB, revs0 ...*Revision) error {
 	var c Collection
 
 	for _, rev := range revs0 {
 		c.Put(rev)
 	}
 
+	// Dependencies that are not being rolled back have no bearing on the
+	// order.
+	revs, err := c.sort(func(string) (bool, error) { return true, nil })
+
+	if err != nil {
+		return err
+	}
+
 	errs := Errors(make([]error, 0, len(revs0)))
-	revs := c.Slice()
 
 	for _, rev := range revs {
-		if err := rev.Perform(db); err != nil {
-			if errors.Is(err, ErrPerformed) {
-				errs = append(errs, err)
-				continue
-			}
+		if rev.Down == "" {
+			errs = append(errs, &RevisionError{
+				ID:  rev.Slug(),
+				Err: ErrIrreversible,
+			})
+		}
+	}
+
+	if err := errs.err(); err != nil {
+		return err
+	}
+
+	l, err := AcquireLockContext(ctx, db)
+
+	if err != nil {
+		return err
+	}
+
+	defer l.Release()
+
+	for i := len(revs) - 1; i >= 0; i-- {
+		if err := revs[i].RollbackContext(ctx, db); err != nil {
 			return err
 		}
 	}
-	return errs.err()
+	return nil
 }
 
 // LoadRevisions loads all of the revisions from the given directory. This will
@@ -293,11 +687,61 @@ func OpenRevision(path string) (*Revision, error) {
 	return UnmarshalRevision(f)
 }
 
+// LoadRevisionsFS loads all of the revisions from the given directory in the
+// given filesystem. This behaves the same as LoadRevisions, and can be used to
+// load revisions that have been embedded via embed.FS.
+func LoadRevisionsFS(fsys fs.FS, dir string) ([]*Revision, error) {
+	revs := make([]*Revision, 0)
+
+	visit := func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		if !strings.HasSuffix(path, ".sql") {
+			return nil
+		}
+
+		rev, err := OpenRevisionFS(fsys, path)
+
+		if err != nil {
+			return err
+		}
+
+		revs = append(revs, rev)
+		return nil
+	}
+
+	if err := fs.WalkDir(fsys, dir, visit); err != nil {
+		return nil, err
+	}
+	return revs, nil
+}
+
+// OpenRevisionFS opens the revision at the given path in the given filesystem.
+func OpenRevisionFS(fsys fs.FS, path string) (*Revision, error) {
+	f, err := fsys.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return UnmarshalRevision(f)
+}
+
 // UnmarshalRevision will unmarshal a Revision from the given io.Reader. This
 // will expect to see a comment block header that contains the metadata about
 // the Revision itself. This will check to see if the given Revision ID is
 // valid. A Revision id is considered valid when it can be parsed into a
-// valid time via time.Parse using the layout of 20060102150405.
+// valid time via time.Parse using the layout of 20060102150405. If the SQL
+// contains a "-- mgrt:down" comment on its own line, then everything after that
+// comment will be treated as the down SQL of the Revision.
 func UnmarshalRevision(r io.Reader) (*Revision, error) {
 	br := bufio.NewReader(r)
 
@@ -307,6 +751,8 @@ func UnmarshalRevision(r io.Reader) (*Revision, error) {
 		buf     []rune = make([]rune, 0)
 		r0      rune
 		inBlock bool
+		lines   int
+		base    = 1 // base is the line in the revision that buf starts on.
 	)
 
 	for {
@@ -316,10 +762,20 @@ func UnmarshalRevision(r io.Reader) (*Revision, error) {
 			if err != io.EOF {
 				return nil, err
 			}
-			rev.SQL = strings.TrimSpace(string(buf))
+
+			var line, downLine int
+
+			rev.SQL, line, rev.Down, downLine = splitDown(string(buf))
+
+			rev.line = base + line - 1
+			rev.downLine = base + downLine - 1
 			break
 		}
 
+		if r == '\n' {
+			lines++
+		}
+
 		if r == '*' {
 			if r0 == '/' {
 				inBlock = true
@@ -331,6 +787,7 @@ func UnmarshalRevision(r io.Reader) (*Revision, error) {
 			if r0 == '*' {
 				rev.Comment = strings.TrimSpace(string(buf))
 				buf = buf[0:0]
+				base = lines + 1
 				inBlock = false
 				continue
 			}
@@ -358,17 +815,31 @@ func UnmarshalRevision(r io.Reader) (*Revision, error) {
 					goto cont
 				}
 
-				if string(buf[pos-6:pos]) == "Author" {
-					rev.Author = strings.TrimSpace(string(buf[pos+1:]))
-					buf = buf[0:0]
-					continue
+				key := string(buf[:pos])
+
+				if i := strings.LastIndex(key, "\n"); i >= 0 {
+					key = key[i+1:]
 				}
 
-				if string(buf[pos-8:pos]) == "Revision" {
-					rev.ID = strings.TrimSpace(string(buf[pos+1:]))
-					buf = buf[0:0]
-					continue
+				val := strings.TrimSpace(string(buf[pos+1:]))
+
+				switch strings.TrimSpace(key) {
+				case "Author":
+					rev.Author = val
+				case "Revision":
+					rev.ID = val
+				case "Transaction":
+					rev.NoTransaction = val == "none"
+				case "Depends":
+					rev.Depends = strings.FieldsFunc(val, func(r rune) bool {
+						return r == ',' || unicode.IsSpace(r)
+					})
+				default:
+					goto cont
 				}
+
+				buf = buf[0:0]
+				continue
 			}
 		}
 
@@ -406,6 +877,36 @@ func UnmarshalRevision(r io.Reader) (*Revision, error) {
 	return rev, nil
 }
 
+// checksum returns the hex encoded SHA-256 of the given SQL.
+func checksum(sql string) string {
+	sum := sha256.Sum256([]byte(sql))
+	return hex.EncodeToString(sum[:])
+}
+
+// leadingLines returns the number of lines in the leading whitespace of s.
+func leadingLines(s string) int {
+	return strings.Count(s[:len(s)-len(strings.TrimLeft(s, " \t\r\n"))], "\n")
+}
+
+// splitDown splits the given SQL into the SQL to perform and the SQL to
+// rollback. These are separated by the downMarker comment on its own line.
+// The line in the given SQL that each of these start on is also returned.
+func splitDown(s string) (string, int, string, int) {
+	var off int
+
+	line := 1 + leadingLines(s)
+
+	for i, part := range strings.SplitAfter(s, "\n") {
+		if strings.TrimSpace(part) == downMarker {
+			down := s[off+len(part):]
+
+			return strings.TrimSpace(s[:off]), line, strings.TrimSpace(down), i + 2 + leadingLines(down)
+		}
+		off += len(part)
+	}
+	return strings.TrimSpace(s), line, "", 0
+}
+
 func (n *node) walk(visit func(*Revision)) {
 	if n.left != nil {
 		n.left.walk(visit)
@@ -470,10 +971,133 @@ func (c *Collection) Slice() []*Revision {
 	return revs
 }
 
+// Sort returns a slice of all the revisions in the collection sorted so that
+// each Revision comes after the revisions it depends on. Revisions are
+// otherwise kept in ascending order. If a Revision depends on a Revision that
+// is not in the collection, then a *RevisionError wrapping ErrMissingDependency
+// is returned. If the dependencies of a Revision form a cycle, then a
+// *RevisionError wrapping ErrDependencyCycle is returned.
+func (c *Collection) Sort() ([]*Revision, error) {
+	return c.sort(func(string) (bool, error) { return false, nil })
+}
+
+// sort sorts the revisions in the collection by their dependencies. The given
+// function is called for each dependency that is not in the collection, to
+// check whether it can be treated as satisfied, such as when it has already
+// been performed.
+func (c *Collection) sort(satisfied func(string) (bool, error)) ([]*Revision, error) {
+	revs := c.Slice()
+
+	done := make(map[string]bool)
+	slugs := make(map[string]struct{})
+
+	for _, rev := range revs {
+		slugs[rev.Slug()] = struct{}{}
+	}
+
+	for _, rev := range revs {
+		for _, dep := range rev.Depends {
+			if _, ok := slugs[dep]; ok {
+				continue
+			}
+
+			ok, err := satisfied(dep)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if !ok {
+				return nil, &RevisionError{
+					ID:  rev.Slug(),
+					Err: fmt.Errorf("%w: %s", ErrMissingDependency, dep),
+				}
+			}
+			done[dep] = true
+		}
+	}
+
+	sorted := make([]*Revision, 0, len(revs))
+
+	for len(revs) > 0 {
+		next := -1
+
+	find:
+		for i, rev := range revs {
+			for _, dep := range rev.Depends {
+				if !done[dep] {
+					continue find
+				}
+			}
+			next = i
+			break
+		}
+
+		if next < 0 {
+			return nil, &RevisionError{
+				ID:  dependencyCycle(revs, done),
+				Err: ErrDependencyCycle,
+			}
+		}
+
+		rev := revs[next]
+
+		done[rev.Slug()] = true
+		sorted = append(sorted, rev)
+		revs = append(revs[:next], revs[next+1:]...)
+	}
+	return sorted, nil
+}
+
+// dependencyCycle returns the slug of a Revision in the given revisions that
+// is part of a dependency cycle. This follows the dependencies that are not
+// done from the first Revision until a Revision is seen twice.
+func dependencyCycle(revs []*Revision, done map[string]bool) string {
+	bySlug := make(map[string]*Revision)
+
+	for _, rev := range revs {
+		bySlug[rev.Slug()] = rev
+	}
+
+	seen := make(map[string]struct{})
+	rev := revs[0]
+
+	for {
+		if _, ok := seen[rev.Slug()]; ok {
+			return rev.Slug()
+		}
+		seen[rev.Slug()] = struct{}{}
+
+		for _, dep := range rev.Depends {
+			if !done[dep] {
+				rev = bySlug[dep]
+				break
+			}
+		}
+	}
+}
+
 func (e *RevisionError) Error() string {
-	return "revision error " + e.ID + ": " + e.Err.Error()
+	s := "revision error " + e.ID
+
+	if e.Statement > 0 {
+		s += ": statement " + strconv.Itoa(e.Statement)
+
+		if e.Line > 0 {
+			s += " on line " + strconv.Itoa(e.Line)
+		}
+	}
+
+	if e.RolledBack {
+		s += ": rolled back"
+	}
+	return s + ": " + e.Err.Error()
 }
 
+func (e *statementError) Error() string { return e.err.Error() }
+
+func (e *statementError) Unwrap() error { return e.err }
+
 // Unwrap returns the underlying error that caused the original RevisionError.
 func (e *RevisionError) Unwrap() error { return e.Err }
 
@@ -489,25 +1113,263 @@ func (r *Revision) Slug() string {
 // Perform will perform the current Revision against the given database. If
 // the Revision is emtpy, then nothing happens. If the Revision has already
 // been performed, then ErrPerformed is returned.
+//
+// If the database supports transactional DDL, then the Revision and its log
+// entry will be performed inside of a single transaction, unless NoTransaction
+// is set on the Revision. A Revision with a Func is always performed inside of
+// a transaction. If the Revision fails, then the transaction will be rolled
+// back, and the returned *RevisionError will have RolledBack set.
+//
+// If an Observer is set on the database, then it is notified before and after
+// the Revision is performed, if the Revision fails, or if the Revision is
+// skipped because it has already been performed.
 func (r *Revision) Perform(db *DB) error {
-	if r.SQL == "" {
+	return r.PerformContext(context.Background(), db)
+}
+
+// PerformContext will perform the current Revision against the given database
+// using the given context. This behaves the same as Perform.
+func (r *Revision) PerformContext(ctx context.Context, db *DB) error {
+	if r.SQL == "" && r.Func == nil {
 		return nil
 	}
 
-	if err := RevisionPerformed(db, r); err != nil {
+	obs := db.observer()
+
+	if err := RevisionPerformedContext(ctx, db, r); err != nil {
+		if errors.Is(err, ErrPerformed) {
+			db.logAttrs(ctx, slog.LevelInfo, "revision skipped", r.attrs(slog.String("reason", ErrPerformed.Error()))...)
+
+			obs.OnSkip(ctx, r, err)
+			return err
+		}
+
+		db.logAttrs(ctx, slog.LevelError, "revision failed", r.attrs(slog.String("error", err.Error()))...)
+
+		obs.OnError(ctx, r, 0, err)
 		return err
 	}
 
-	if _, err := db.Exec(r.SQL); err != nil {
+	db.logAttrs(ctx, slog.LevelDebug, "performing revision", r.attrs()...)
+
+	obs.BeforePerform(ctx, r)
+
+	start := time.Now()
+
+	rows, err := r.attempt(ctx, db)
+
+	d := time.Since(start)
+
+	if err != nil {
+		// The given context may have been cancelled, so the failure is
+		// recorded regardless.
+		recordFailure(context.Background(), db, r, err, d)
+
+		db.logAttrs(ctx, slog.LevelError, "revision failed", r.attrs(slog.Duration("duration", d), slog.String("error", err.Error()))...)
+
+		obs.OnError(ctx, r, d, err)
+		return err
+	}
+
+	db.logAttrs(ctx, slog.LevelInfo, "revision performed", r.attrs(slog.Duration("duration", d), slog.Int64("rows", rows))...)
+
+	obs.AfterPerform(ctx, r, d)
+	return nil
+}
+
+// attrs returns the attributes for logging the Revision, followed by the given
+// attributes.
+func (r *Revision) attrs(extra ...slog.Attr) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("revision", r.Slug()),
+		slog.String("category", r.Category),
+	}
+	return append(attrs, extra...)
+}
+
+// attempt performs the Revision against the given database, inside of a
+// transaction if need be. This returns the number of rows affected by the SQL
+// of the Revision.
+func (r *Revision) attempt(ctx context.Context, db *DB) (int64, error) {
+	if r.Func == nil && (r.NoTransaction || !db.Dialect.Transactional()) {
+		rows, err := r.perform(ctx, db, db.DB)
+
+		if err != nil {
+			return 0, r.error(err)
+		}
+		return rows, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return 0, &RevisionError{
+			ID:  r.Slug(),
+			Err: err,
+		}
+	}
+
+	rows, err := r.perform(ctx, db, tx)
+
+	if err != nil {
+		rerr := r.error(err)
+		rerr.RolledBack = tx.Rollback() == nil
+		return 0, rerr
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, &RevisionError{
+			ID:  r.Slug(),
+			Err: err,
+		}
+	}
+	return rows, nil
+}
+
+// error returns a *RevisionError for the given error that occurred when
+// performing or rolling back the Revision. If the error was caused by a
+// statement, then the position and line of the statement are recorded.
+func (r *Revision) error(err error) *RevisionError {
+	rerr := &RevisionError{
+		ID:  r.Slug(),
+		Err: err,
+	}
+
+	if serr, ok := err.(*statementError); ok {
+		rerr.Err = serr.err
+		rerr.Statement = serr.index
+		rerr.Line = serr.line
+	}
+	return rerr
+}
+
+// execStatements splits the given SQL into statements via the database's Split
+// function, and executes each of them in turn. The given line is the line in
+// the revision file that the SQL starts on, if the SQL was unmarshalled. If a
+// statement fails, then a *statementError is returned. This returns the total
+// number of rows affected by the statements, if the driver reports them.
+func execStatements(ctx context.Context, db *DB, e execer, sql string, line int) (int64, error) {
+	var rows int64
+
+	for i, stmt := range db.split(sql) {
+		res, err := e.ExecContext(ctx, stmt.SQL)
+
+		if err != nil {
+			serr := &statementError{
+				index: i + 1,
+				line:  stmt.Line,
+				err:   err,
+			}
+
+			if line > 0 {
+				serr.line += line - 1
+			}
+			return 0, serr
+		}
+
+		if n, err := res.RowsAffected(); err == nil {
+			rows += n
+		}
+	}
+	return rows, nil
+}
+
+// perform executes the SQL of the Revision followed by the insertion of the
+// Revision into the log. This returns the number of rows affected by the SQL,
+// this will be 0 for a Revision with a Func.
+func (r *Revision) perform(ctx context.Context, db *DB, e execer) (int64, error) {
+	var (
+		sum  string
+		rows int64
+	)
+
+	start := time.Now()
+
+	if r.Func != nil {
+		// Revisions with a Func are always performed in a transaction.
+		if err := r.Func(ctx, e.(*sql.Tx)); err != nil {
+			return 0, err
+		}
+	} else {
+		n, err := execStatements(ctx, db, e, r.SQL, r.line)
+
+		if err != nil {
+			return 0, err
+		}
+
+		rows = n
+		sum = checksum(r.SQL)
+	}
+
+	if err := r.log(ctx, db, e, sum, time.Since(start), false, false); err != nil {
+		return 0, err
+	}
+	return rows, nil
+}
+
+// log inserts the Revision into the revision log with the given checksum, and
+// the duration it took to execute. Whether the Revision was logged via
+// Baseline or Mark, without being executed, is recorded too.
+func (r *Revision) log(ctx context.Context, db *DB, e execer, sum string, d time.Duration, baselined, marked bool) error {
+	q := db.parameterize("INSERT INTO " + db.tableName() + " (" + db.columns(revisionColumns) + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+
+	_, err := e.ExecContext(ctx, q, r.Slug(), r.Author, r.Comment, r.SQL, r.Down, sum, time.Now().Unix(), baselined, d.Milliseconds(), db.Version, db.performer(), r.NoTransaction, strings.Join(r.Depends, ","), marked)
+	return err
+}
+
+// Rollback will rollback the current Revision against the given database by
+// performing its down SQL, and removing it from the revision log. If the
+// Revision has no down SQL, then ErrIrreversible is returned. If the Revision
+// has not been performed, then ErrNotFound is returned.
+//
+// Just like Perform, this will be done inside of a transaction if the database
+// supports transactional DDL, unless NoTransaction is set on the Revision.
+func (r *Revision) Rollback(db *DB) error {
+	return r.RollbackContext(context.Background(), db)
+}
+
+// RollbackContext will rollback the current Revision against the given
+// database using the given context. This behaves the same as Rollback.
+func (r *Revision) RollbackContext(ctx context.Context, db *DB) error {
+	if r.Down == "" {
+		return &RevisionError{
+			ID:  r.Slug(),
+			Err: ErrIrreversible,
+		}
+	}
+
+	if err := RevisionPerformedContext(ctx, db, r); err == nil {
+		return &RevisionError{
+			ID:  r.Slug(),
+			Err: ErrNotFound,
+		}
+	} else if !errors.Is(err, ErrPerformed) {
+		return err
+	}
+
+	if r.NoTransaction || !db.Dialect.Transactional() {
+		if err := r.rollback(ctx, db, db.DB); err != nil {
+			return r.error(err)
+		}
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+
+	if err != nil {
 		return &RevisionError{
 			ID:  r.Slug(),
 			Err: err,
 		}
 	}
 
-	q := db.Parameterize("INSERT INTO mgrt_revisions (id, author, comment, sql, performed_at) VALUES (?, ?, ?, ?, ?)")
+	if err := r.rollback(ctx, db, tx); err != nil {
+		rerr := r.error(err)
+		rerr.RolledBack = tx.Rollback() == nil
+		return rerr
+	}
 
-	if _, err := db.Exec(q, r.Slug(), r.Author, r.Comment, r.SQL, time.Now().Unix()); err != nil {
+	if err := tx.Commit(); err != nil {
 		return &RevisionError{
 			ID:  r.Slug(),
 			Err: err,
@@ -516,6 +1378,19 @@ func (r *Revision) Perform(db *DB) error {
 	return nil
 }
 
+// rollback executes the down SQL of the Revision followed by the removal of
+// the Revision from the log.
+func (r *Revision) rollback(ctx context.Context, db *DB, e execer) error {
+	if _, err := execStatements(ctx, db, e, r.Down, r.downLine); err != nil {
+		return err
+	}
+
+	q := db.parameterize("DELETE FROM " + db.tableName() + " WHERE (id = ?)")
+
+	_, err := e.ExecContext(ctx, q, r.Slug())
+	return err
+}
+
 // Title will extract the title from the comment of the current Revision. First,
 // this will truncate the title to being 72 characters. If the comment was longer
 // than 72 characters, then the title will be suffixed with "...". If a LF
@@ -539,7 +1414,8 @@ func (r *Revision) Title() string {
 }
 
 // String returns the string representation of the Revision. This will be the
-// comment block header followed by the Revision SQL itself.
+// comment block header followed by the Revision SQL itself, and the down SQL
+// if any.
 func (r *Revision) String() string {
 	var buf bytes.Buffer
 
@@ -547,10 +1423,23 @@ func (r *Revision) String() string {
 	buf.WriteString("Revision: " + r.Slug() + "\n")
 	buf.WriteString("Author:   " + r.Author + "\n")
 
+	if r.NoTransaction {
+		buf.WriteString("Transaction: none\n")
+	}
+
+	if len(r.Depends) > 0 {
+		buf.WriteString("Depends:  " + strings.Join(r.Depends, ", ") + "\n")
+	}
+
 	if r.Comment != "" {
 		buf.WriteString("\n" + r.Comment + "\n")
 	}
 	buf.WriteString("*/\n\n")
 	buf.WriteString(r.SQL)
+
+	if r.Down != "" {
+		buf.WriteString("\n\n" + downMarker + "\n\n")
+		buf.WriteString(r.Down)
+	}
 	return buf.String()
 }
@@ -1,11 +1,15 @@
 package mgrt
 
 import (
+	"context"
+	"database/sql"
 	"errors"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -49,6 +53,79 @@ DROP TABLE users;`)
 	}
 }
 
+func Test_UnmarshalRevisionNoTransaction(t *testing.T) {
+	r := strings.NewReader(`/*
+Revision: 20060102150405
+Author:   Author <me@example.com>
+Transaction: none
+
+Add index on users
+*/
+CREATE INDEX CONCURRENTLY users_email_idx ON users (email);`)
+
+	rev, err := UnmarshalRevision(r)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rev.NoTransaction {
+		t.Errorf("expected revision to not be performed in a transaction\n")
+	}
+
+	if rev.Comment != "Add index on users" {
+		t.Errorf("unexpected revision comment, expected=%q, got=%q\n", "Add index on users", rev.Comment)
+	}
+
+	rev, err = UnmarshalRevision(strings.NewReader(rev.String()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rev.NoTransaction {
+		t.Errorf("expected revision to not be performed in a transaction after marshalling\n")
+	}
+}
+
+func Test_UnmarshalRevisionDown(t *testing.T) {
+	r := strings.NewReader(`/*
+Revision: 20060102150405
+Author:   Author <me@example.com>
+
+Add users table
+*/
+CREATE TABLE users ( id INT NOT NULL UNIQUE );
+
+-- mgrt:down
+
+DROP TABLE users;`)
+
+	rev, err := UnmarshalRevision(r)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rev.SQL != "CREATE TABLE users ( id INT NOT NULL UNIQUE );" {
+		t.Errorf("unexpected revision sql, expected=%q, got=%q\n", "CREATE TABLE users ( id INT NOT NULL UNIQUE );", rev.SQL)
+	}
+
+	if rev.Down != "DROP TABLE users;" {
+		t.Errorf("unexpected revision down, expected=%q, got=%q\n", "DROP TABLE users;", rev.Down)
+	}
+
+	rev2, err := UnmarshalRevision(strings.NewReader(rev.String()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rev2.SQL != rev.SQL || rev2.Down != rev.Down {
+		t.Errorf("unexpected revision after marshalling, expected=%q, got=%q\n", rev.String(), rev2.String())
+	}
+}
+
 func Test_RevisionTitle(t *testing.T) {
 	singleLineComment := "A title that is longer than 72 characters in length this should be trimmed with an ellipsis."
 	multiLineComment := `A comment that will have multiple lines and a long title line
@@ -238,3 +315,864 @@ func Test_LoadRevisions(t *testing.T) {
 		t.Fatalf("unexpected revision count, expected=%d, got=%d\n", l, 2)
 	}
 }
+
+func Test_LoadRevisionsFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"revisions/20060102150405.sql": &fstest.MapFile{
+			Data: []byte(`/*
+Revision: 20060102150405
+Author:   Author <me@example.com>
+
+Add users table
+*/
+CREATE TABLE users ( id INT NOT NULL UNIQUE );`),
+		},
+		"revisions/users/20060102150406.sql": &fstest.MapFile{
+			Data: []byte(`/*
+Revision: users/20060102150406
+Author:   Author <me@example.com>
+
+Add username to users table
+*/
+ALTER TABLE users ADD COLUMN username VARCHAR NOT NULL;`),
+		},
+		"revisions/readme.md": &fstest.MapFile{
+			Data: []byte("Not a revision"),
+		},
+	}
+
+	revs, err := LoadRevisionsFS(fsys, "revisions")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"20060102150405", "users/20060102150406"}
+
+	if len(revs) != len(expected) {
+		t.Fatalf("unexpected revision count, expected=%d, got=%d\n", len(expected), len(revs))
+	}
+
+	for i, rev := range revs {
+		if rev.Slug() != expected[i] {
+			t.Errorf("revs[%d] - unexpected revision, expected=%q, got=%q\n", i, expected[i], rev.Slug())
+		}
+	}
+
+	rev, err := OpenRevisionFS(fsys, "revisions/users/20060102150406.sql")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rev.Category != "users" {
+		t.Errorf("unexpected revision category, expected=%q, got=%q\n", "users", rev.Category)
+	}
+
+	if _, err := OpenRevisionFS(fsys, "revisions/20060102150407.sql"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unexpected error, expected=%v, got=%v\n", fs.ErrNotExist, err)
+	}
+}
+
+func Test_RevisionPerformRollback(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	rev := NewRevision("Andrew", "Add users table")
+	rev.ID = "20060102150405"
+	rev.SQL = `CREATE TABLE users ( id INT NOT NULL UNIQUE );
+INSERT INTO posts (id) VALUES (1);`
+
+	err = rev.Perform(db)
+
+	var reverr *RevisionError
+
+	if !errors.As(err, &reverr) {
+		t.Fatalf("unexpected error, expected=%T, got=%T\n", reverr, err)
+	}
+
+	if !reverr.RolledBack {
+		t.Fatalf("expected revision to be rolled back\n")
+	}
+
+	if err := RevisionPerformed(db, rev); err != nil {
+		t.Fatalf("expected revision to not be logged, got %q\n", err)
+	}
+
+	if _, err := db.Exec("SELECT id FROM users"); err == nil {
+		t.Fatalf("expected users table to not exist after rollback\n")
+	}
+}
+
+func Test_RevisionRollbackNoTransaction(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	// VACUUM cannot be run inside of a transaction.
+	rev := NewRevision("Andrew", "Add users table")
+	rev.ID = "20060102150405"
+	rev.SQL = "CREATE TABLE users ( id INT NOT NULL UNIQUE );"
+	rev.Down = "DROP TABLE users;\nVACUUM;"
+	rev.NoTransaction = true
+
+	if err := rev.Perform(db); err != nil {
+		t.Fatal(err)
+	}
+
+	logged, err := GetRevision(db, rev.ID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !logged.NoTransaction {
+		t.Fatalf("expected logged revision to have NoTransaction set\n")
+	}
+
+	if err := logged.Rollback(db); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec("SELECT id FROM users"); err == nil {
+		t.Fatalf("expected users table to not exist after rollback\n")
+	}
+}
+
+func Test_RollbackRevisions(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tests := []struct {
+		id   string
+		sql  string
+		down string
+	}{
+		{
+			"20060102150405",
+			"CREATE TABLE users ( id INT NOT NULL UNIQUE );",
+			"DROP TABLE users;",
+		},
+		{
+			"20060102150406",
+			"CREATE TABLE posts ( id INT NOT NULL UNIQUE );",
+			"",
+		},
+		{
+			"20060102150407",
+			"ALTER TABLE users ADD COLUMN username VARCHAR NOT NULL;",
+			"ALTER TABLE users DROP COLUMN username;",
+		},
+	}
+
+	revs := make([]*Revision, 0, len(tests))
+
+	for _, test := range tests {
+		rev := NewRevision("Andrew", "")
+		rev.ID = test.id
+		rev.SQL = test.sql
+		rev.Down = test.down
+
+		revs = append(revs, rev)
+	}
+
+	if err := PerformRevisions(db, revs...); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RollbackRevisions(db, revs...); !errors.Is(err.(Errors)[0], ErrIrreversible) {
+		t.Fatalf("unexpected error, expected=%T, got=%T\n", ErrIrreversible, err)
+	}
+
+	logged, err := GetRevisions(db, -1)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logged) != len(tests) {
+		t.Fatalf("unexpected revision count, expected=%d, got=%d\n", len(tests), len(logged))
+	}
+
+	reversible := make([]*Revision, 0, len(logged))
+
+	for _, rev := range logged {
+		if rev.Down != "" {
+			reversible = append(reversible, rev)
+		}
+	}
+
+	if err := RollbackRevisions(db, reversible...); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec("SELECT id FROM users"); err == nil {
+		t.Fatalf("expected users table to not exist after rollback\n")
+	}
+
+	if err := revs[0].Rollback(db); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unexpected error, expected=%T, got=%T\n", ErrNotFound, err)
+	}
+
+	logged, err = GetRevisions(db, -1)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logged) != 1 {
+		t.Fatalf("unexpected revision count, expected=%d, got=%d\n", 1, len(logged))
+	}
+}
+
+func Test_RollbackRevisionsDepends(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	// The older revision depends on the newer one, so it is performed after
+	// it, and must be rolled back before it.
+	users := NewRevision("Andrew", "Add users table")
+	users.ID = "20060102150406"
+	users.SQL = "CREATE TABLE users ( id INT NOT NULL UNIQUE );"
+	users.Down = "DROP TABLE users;"
+
+	admin := NewRevision("Andrew", "Add admin user")
+	admin.ID = "20060102150405"
+	admin.SQL = "INSERT INTO users (id) VALUES (1);"
+	admin.Down = "DELETE FROM users WHERE (id = 1);"
+	admin.Depends = []string{users.Slug()}
+
+	if err := PerformRevisions(db, admin, users); err != nil {
+		t.Fatal(err)
+	}
+
+	logged, err := GetRevisions(db, -1)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rev := range logged {
+		if rev.ID == admin.ID && (len(rev.Depends) != 1 || rev.Depends[0] != users.Slug()) {
+			t.Fatalf("unexpected revision depends, expected=%v, got=%v\n", admin.Depends, rev.Depends)
+		}
+	}
+
+	if err := RollbackRevisions(db, logged...); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec("SELECT id FROM users"); err == nil {
+		t.Fatalf("expected users table to not exist after rollback\n")
+	}
+}
+
+func Test_VerifyRevisions(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	rev := NewRevision("Andrew", "Add users table")
+	rev.ID = "20060102150405"
+	rev.SQL = "CREATE TABLE users ( id INT NOT NULL UNIQUE );"
+
+	pending := NewRevision("Andrew", "Add posts table")
+	pending.ID = "20060102150406"
+	pending.SQL = "CREATE TABLE posts ( id INT NOT NULL UNIQUE );"
+
+	if err := rev.Perform(db); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyRevisions(db, rev, pending); err != nil {
+		t.Fatal(err)
+	}
+
+	rev.SQL = "CREATE TABLE users ( id INT NOT NULL UNIQUE, email VARCHAR NOT NULL );"
+
+	err = VerifyRevisions(db, rev, pending)
+
+	errs, ok := err.(Errors)
+
+	if !ok {
+		t.Fatalf("unexpected error, expected=%T, got=%T\n", errs, err)
+	}
+
+	if len(errs) != 1 {
+		t.Fatalf("unexpected error count, expected=%d, got=%d\n", 1, len(errs))
+	}
+
+	if !errors.Is(errs[0], ErrChecksum) {
+		t.Fatalf("unexpected error, expected=%T, got=%T\n", ErrChecksum, errs[0])
+	}
+}
+
+func Test_RevisionPerformContext(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	rev := NewRevision("Andrew", "Add users table")
+	rev.ID = "20060102150405"
+	rev.SQL = "CREATE TABLE users ( id INT NOT NULL UNIQUE );"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := PerformRevisionsContext(ctx, db, rev); !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error, expected=%T, got=%T\n", context.Canceled, err)
+	}
+
+	if err := RevisionPerformed(db, rev); err != nil {
+		t.Fatalf("expected revision to not be performed, got %q\n", err)
+	}
+}
+
+func Test_RevisionPerformStatementError(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	rev, err := UnmarshalRevision(strings.NewReader(`/*
+Revision: 20060102150405
+Author:   Author <me@example.com>
+
+Add users and posts tables
+*/
+CREATE TABLE users ( id INT NOT NULL UNIQUE );
+
+-- Reference a table that does not exist.
+CREATE TABLE posts ( id INT NOT NULL UNIQUE );
+INSERT INTO comments (id) VALUES (1);
+`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = rev.Perform(db)
+
+	rerr, ok := err.(*RevisionError)
+
+	if !ok {
+		t.Fatalf("unexpected error, expected=%T, got=%T\n", rerr, err)
+	}
+
+	if rerr.Statement != 3 {
+		t.Errorf("unexpected statement, expected=%d, got=%d\n", 3, rerr.Statement)
+	}
+
+	if rerr.Line != 11 {
+		t.Errorf("unexpected line, expected=%d, got=%d\n", 11, rerr.Line)
+	}
+
+	if !rerr.RolledBack {
+		t.Errorf("expected revision to be rolled back\n")
+	}
+}
+
+func Test_RevisionFunc(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	rev := NewRevision("Andrew", "Add users table")
+	rev.ID = "20060102150405"
+	rev.SQL = "CREATE TABLE users ( id INT NOT NULL UNIQUE, username VARCHAR NOT NULL );"
+
+	backfill := NewRevisionFunc("users/20060102150406", "Andrew", "Backfill users", func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO users (id, username) VALUES (1, 'admin')")
+		return err
+	})
+
+	if backfill.Category != "users" || backfill.ID != "20060102150406" {
+		t.Fatalf("unexpected revision, expected=%q, got=%q\n", "users/20060102150406", backfill.Slug())
+	}
+
+	fail := NewRevisionFunc("20060102150407", "Andrew", "Fail", func(ctx context.Context, tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO users (id, username) VALUES (2, 'guest')"); err != nil {
+			return err
+		}
+		return errors.New("backfill failed")
+	})
+
+	if err := PerformRevisions(db, backfill, rev); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 1 {
+		t.Fatalf("unexpected user count, expected=%d, got=%d\n", 1, n)
+	}
+
+	if _, err := GetRevision(db, "users/20060102150406"); err != nil {
+		t.Fatal(err)
+	}
+
+	err = fail.Perform(db)
+
+	rerr, ok := err.(*RevisionError)
+
+	if !ok {
+		t.Fatalf("unexpected error, expected=%T, got=%T\n", rerr, err)
+	}
+
+	if !rerr.RolledBack {
+		t.Errorf("expected revision to be rolled back\n")
+	}
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 1 {
+		t.Fatalf("unexpected user count, expected=%d, got=%d\n", 1, n)
+	}
+
+	if err := VerifyRevisions(db, rev, backfill); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_RegisterRevision(t *testing.T) {
+	fn := func(ctx context.Context, tx *sql.Tx) error { return nil }
+
+	// Restore the registry afterwards, so the test can be run more than once.
+	revMu.Lock()
+	orig := registered
+	registered = make(map[string]*Revision)
+	revMu.Unlock()
+
+	t.Cleanup(func() {
+		revMu.Lock()
+		registered = orig
+		revMu.Unlock()
+	})
+
+	RegisterRevision(NewRevisionFunc("20060102150406", "Andrew", "", fn))
+	RegisterRevision(NewRevisionFunc("20060102150405", "Andrew", "", fn))
+
+	revs := RegisteredRevisions()
+
+	if len(revs) != 2 {
+		t.Fatalf("unexpected revision count, expected=%d, got=%d\n", 2, len(revs))
+	}
+
+	if revs[0].ID != "20060102150405" {
+		t.Errorf("unexpected revision, expected=%q, got=%q\n", "20060102150405", revs[0].ID)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected duplicate revision to panic\n")
+		}
+	}()
+
+	RegisterRevision(NewRevisionFunc("20060102150405", "Andrew", "", fn))
+}
+
+func Test_UnmarshalRevisionDepends(t *testing.T) {
+	r := strings.NewReader(`/*
+Revision: 20060102150405
+Author:   Author <me@example.com>
+Depends:  users/20060102150407, 20060102150408
+
+Add posts table
+*/
+CREATE TABLE posts ( id INT NOT NULL UNIQUE );`)
+
+	rev, err := UnmarshalRevision(r)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"users/20060102150407", "20060102150408"}
+
+	if len(rev.Depends) != len(expected) {
+		t.Fatalf("unexpected revision depends, expected=%v, got=%v\n", expected, rev.Depends)
+	}
+
+	for i, dep := range rev.Depends {
+		if dep != expected[i] {
+			t.Errorf("unexpected revision depends, expected=%v, got=%v\n", expected, rev.Depends)
+		}
+	}
+
+	rev2, err := UnmarshalRevision(strings.NewReader(rev.String()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(rev2.Depends, ",") != strings.Join(rev.Depends, ",") {
+		t.Errorf("unexpected revision after marshalling, expected=%q, got=%q\n", rev.String(), rev2.String())
+	}
+}
+
+func Test_CollectionSort(t *testing.T) {
+	tests := []struct {
+		revs     [][]string
+		expected []string
+		err      error
+	}{
+		{
+			[][]string{
+				{"20060102150407"},
+				{"20060102150405", "20060102150406"},
+				{"20060102150406"},
+			},
+			[]string{"20060102150406", "20060102150405", "20060102150407"},
+			nil,
+		},
+		{
+			[][]string{
+				{"20060102150405", "20060102150407"},
+				{"20060102150406"},
+				{"20060102150407", "20060102150406"},
+			},
+			[]string{"20060102150406", "20060102150407", "20060102150405"},
+			nil,
+		},
+		{
+			[][]string{
+				{"20060102150405", "20060102150409"},
+				{"20060102150406"},
+			},
+			nil,
+			ErrMissingDependency,
+		},
+		{
+			[][]string{
+				{"20060102150405"},
+				{"20060102150406", "20060102150407"},
+				{"20060102150407", "20060102150406"},
+			},
+			nil,
+			ErrDependencyCycle,
+		},
+	}
+
+	for i, test := range tests {
+		var c Collection
+
+		for _, ids := range test.revs {
+			c.Put(&Revision{
+				ID:      ids[0],
+				Depends: ids[1:],
+			})
+		}
+
+		revs, err := c.Sort()
+
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("tests[%d] - unexpected error, expected=%v, got=%v\n", i, test.err, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("tests[%d] - %s\n", i, err)
+		}
+
+		for j, rev := range revs {
+			if rev.ID != test.expected[j] {
+				t.Errorf("tests[%d] - unexpected revision at %d, expected=%q, got=%q\n", i, j, test.expected[j], rev.ID)
+			}
+		}
+	}
+}
+
+func Test_SortRevisions(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	users := NewRevision("Andrew", "Add users table")
+	users.ID = "20060102150405"
+	users.SQL = "CREATE TABLE users ( id INT NOT NULL UNIQUE );"
+
+	if err := users.Perform(db); err != nil {
+		t.Fatal(err)
+	}
+
+	posts := NewRevision("Andrew", "Add posts table")
+	posts.ID = "20060102150406"
+	posts.SQL = "CREATE TABLE posts ( id INT NOT NULL UNIQUE );"
+	posts.Depends = []string{"20060102150405", "20060102150407"}
+
+	comments := NewRevision("Andrew", "Add comments table")
+	comments.ID = "20060102150407"
+	comments.SQL = "CREATE TABLE comments ( id INT NOT NULL UNIQUE );"
+	comments.Depends = []string{"20060102150405"}
+
+	revs, err := SortRevisions(db, posts, comments)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if revs[0] != comments || revs[1] != posts {
+		t.Fatalf("unexpected revision order, expected=[%s %s], got=[%s %s]\n", comments.ID, posts.ID, revs[0].ID, revs[1].ID)
+	}
+
+	comments.Depends = []string{"20060102150408"}
+
+	if _, err := SortRevisions(db, posts, comments); !errors.Is(err, ErrMissingDependency) {
+		t.Fatalf("unexpected error, expected=%v, got=%v\n", ErrMissingDependency, err)
+	}
+}
+
+func Test_CheckOrder(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	newer := NewRevision("Andrew", "Add users table")
+	newer.ID = "20060102150406"
+	newer.SQL = "CREATE TABLE users ( id INT NOT NULL UNIQUE );"
+
+	if err := newer.Perform(db); err != nil {
+		t.Fatal(err)
+	}
+
+	older := NewRevision("Andrew", "Add posts table")
+	older.ID = "20060102150405"
+	older.SQL = "CREATE TABLE posts ( id INT NOT NULL UNIQUE );"
+
+	other := NewRevisionCategory("comments", "Andrew", "Add comments table")
+	other.ID = "20060102150405"
+	other.SQL = "CREATE TABLE comments ( id INT NOT NULL UNIQUE );"
+
+	err = CheckOrder(db, newer, older, other)
+
+	errs, ok := err.(Errors)
+
+	if !ok {
+		t.Fatalf("unexpected error, expected=%T, got=%T\n", errs, err)
+	}
+
+	if len(errs) != 1 || !errors.Is(errs[0], ErrOutOfOrder) {
+		t.Fatalf("unexpected errors, expected=%v, got=%v\n", ErrOutOfOrder, errs)
+	}
+
+	err = PerformRevisions(db, older, other)
+
+	if errs, ok := err.(Errors); !ok || !errors.Is(errs[0], ErrOutOfOrder) {
+		t.Fatalf("unexpected error, expected=%v, got=%v\n", ErrOutOfOrder, err)
+	}
+
+	if err := RevisionPerformed(db, other); err != nil {
+		t.Fatalf("expected revision %s to not be performed, got=%v\n", other.Slug(), err)
+	}
+
+	db.AllowOutOfOrder = true
+
+	if err := PerformRevisions(db, older, other); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_CheckOrderDepends(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	users := NewRevision("Andrew", "Add users table")
+	users.ID = "20060102150408"
+	users.SQL = "CREATE TABLE users ( id INT NOT NULL UNIQUE );"
+	users.Down = "DROP TABLE users;"
+
+	if err := users.Perform(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// A revision merged in from another branch that depends on the newer
+	// revision is not out of order.
+	admin := NewRevision("Andrew", "Add admin user")
+	admin.ID = "20060102150407"
+	admin.SQL = "INSERT INTO users (id) VALUES (1);"
+	admin.Down = "DELETE FROM users WHERE (id = 1);"
+	admin.Depends = []string{users.Slug()}
+
+	posts := NewRevision("Andrew", "Add posts table")
+	posts.ID = "20060102150406"
+	posts.SQL = "CREATE TABLE posts ( id INT NOT NULL UNIQUE );"
+
+	err = CheckOrder(db, admin, posts)
+
+	errs, ok := err.(Errors)
+
+	if !ok {
+		t.Fatalf("unexpected error, expected=%T, got=%T\n", errs, err)
+	}
+
+	if len(errs) != 1 || !errors.Is(errs[0], ErrOutOfOrder) {
+		t.Fatalf("unexpected errors, expected=%v, got=%v\n", ErrOutOfOrder, errs)
+	}
+
+	var reverr *RevisionError
+
+	if !errors.As(errs[0], &reverr) || reverr.ID != posts.Slug() {
+		t.Fatalf("unexpected out of order revision, expected=%q, got=%v\n", posts.Slug(), errs[0])
+	}
+
+	if err := PerformRevisions(db, admin); err != nil {
+		t.Fatal(err)
+	}
+
+	logged, err := GetRevisions(db, -1)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RollbackRevisions(db, logged...); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec("SELECT id FROM users"); err == nil {
+		t.Fatalf("expected users table to not exist after rollback\n")
+	}
+}
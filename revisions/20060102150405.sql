@@ -0,0 +1,13 @@
+/*
+Revision: 20060102150405
+Author:   Andrew Pillar <me@andrewpillar.com>
+
+Add users table
+*/
+CREATE TABLE users (
+	id INT NOT NULL UNIQUE
+);
+
+-- mgrt:down
+
+DROP TABLE users;
@@ -0,0 +1,7 @@
+/*
+Revision: users/20060102150406
+Author:   Andrew Pillar <me@andrewpillar.com>
+
+Add username to users table
+*/
+ALTER TABLE users ADD COLUMN username VARCHAR NOT NULL;
@@ -0,0 +1,286 @@
+package mgrt
+
+import "strings"
+
+// Statement is a single SQL statement that has been split from the SQL of a
+// Revision.
+type Statement struct {
+	SQL  string // SQL is the code of the statement without the delimiter.
+	Line int    // Line is the line the statement starts on in the original SQL.
+}
+
+// splitter splits SQL into individual statements. The fields of the splitter
+// toggle the syntax that is understood, since this varies between dialects.
+type splitter struct {
+	backslash bool // backslash escapes in string literals
+	escape    bool // backslash escapes in E'' string literals
+	backtick  bool // backtick quoted identifiers
+	hash      bool // # line comments
+	dollar    bool // $tag$ dollar quoted string literals
+	delimiter bool // DELIMITER command for changing the statement delimiter
+	triggers  bool // CREATE TRIGGER statements with a BEGIN ... END body
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || c == '$' || (c >= '0' && c <= '9')
+}
+
+// skipQuoted returns the offset in s just after the closing quote q of the
+// quoted literal that starts at offset i. A doubled quote is treated as an
+// escaped quote.
+func skipQuoted(s string, i int, q byte, backslash bool) int {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			if backslash {
+				j++
+			}
+		case q:
+			if j+1 < len(s) && s[j+1] == q {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(s)
+}
+
+// dollarTag returns the dollar quote tag at the start of s, such as $$ or
+// $body$, if any.
+func dollarTag(s string) (string, bool) {
+	j := 1
+
+	for j < len(s) && isIdentChar(s[j]) && s[j] != '$' {
+		j++
+	}
+
+	if j >= len(s) || s[j] != '$' {
+		return "", false
+	}
+
+	if j > 1 && s[1] >= '0' && s[1] <= '9' {
+		return "", false
+	}
+	return s[:j+1], true
+}
+
+// isTrigger checks if the given statement is a CREATE TRIGGER statement.
+func isTrigger(stmt string) bool {
+	fields := strings.Fields(strings.ToUpper(stmt))
+
+	if len(fields) < 2 || fields[0] != "CREATE" {
+		return false
+	}
+
+	if fields[1] == "TEMP" || fields[1] == "TEMPORARY" {
+		fields = fields[1:]
+	}
+	return len(fields) > 1 && fields[1] == "TRIGGER"
+}
+
+// split splits the given SQL into statements. Statements are delimited by a
+// semicolon, unless the delimiter is changed via the DELIMITER command.
+// Delimiters that appear in string literals, quoted identifiers, comments, or
+// trigger bodies are ignored. Statements that only contain comments are
+// omitted.
+func (sp splitter) split(s string) []Statement {
+	var (
+		stmts = make([]Statement, 0)
+		delim = ";"
+		line  = 1
+		from  = -1 // offset of the first code in the current statement
+		first = 0  // line of the first code in the current statement
+		depth = 0  // depth of BEGIN/CASE ... END blocks in the current statement
+	)
+
+	emit := func(end int) {
+		if from >= 0 {
+			stmts = append(stmts, Statement{
+				SQL:  strings.TrimSpace(s[from:end]),
+				Line: first,
+			})
+		}
+		from = -1
+		depth = 0
+	}
+
+	i := 0
+
+	for i < len(s) {
+		c := s[i]
+
+		var next byte
+
+		if i+1 < len(s) {
+			next = s[i+1]
+		}
+
+		switch {
+		case c == '\n':
+			line++
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			i++
+			continue
+		case (c == '-' && next == '-') || (sp.hash && c == '#'):
+			j := strings.IndexByte(s[i:], '\n')
+
+			if j < 0 {
+				j = len(s) - i
+			}
+			i += j
+			continue
+		case c == '/' && next == '*':
+			j := strings.Index(s[i+2:], "*/")
+
+			if j < 0 {
+				j = len(s)
+			} else {
+				j += i + 4
+			}
+
+			line += strings.Count(s[i:j], "\n")
+			i = j
+			continue
+		}
+
+		if strings.HasPrefix(s[i:], delim) {
+			if sp.triggers && depth > 0 && from >= 0 && isTrigger(s[from:i]) {
+				i += len(delim)
+				continue
+			}
+
+			emit(i)
+			i += len(delim)
+			continue
+		}
+
+		if isIdentStart(c) {
+			j := i
+
+			for j < len(s) && isIdentChar(s[j]) && !strings.HasPrefix(s[j:], delim) {
+				j++
+			}
+
+			word := strings.ToUpper(s[i:j])
+
+			if sp.delimiter && from < 0 && word == "DELIMITER" {
+				end := strings.IndexByte(s[j:], '\n')
+
+				if end < 0 {
+					end = len(s) - j
+				}
+
+				if d := strings.TrimSpace(s[j : j+end]); d != "" {
+					delim = d
+				}
+
+				i = j + end
+				continue
+			}
+
+			if from < 0 {
+				from = i
+				first = line
+			}
+
+			if sp.triggers {
+				switch word {
+				case "BEGIN", "CASE":
+					depth++
+				case "END":
+					depth--
+				}
+			}
+
+			i = j
+			continue
+		}
+
+		if from < 0 {
+			from = i
+			first = line
+		}
+
+		j := i + 1
+
+		switch {
+		case c == '\'':
+			escape := sp.escape && i > 0 && (s[i-1] == 'E' || s[i-1] == 'e') && (i < 2 || !isIdentChar(s[i-2]))
+
+			j = skipQuoted(s, i, c, sp.backslash || escape)
+		case c == '"':
+			j = skipQuoted(s, i, c, sp.backslash)
+		case c == '`' && sp.backtick:
+			j = skipQuoted(s, i, c, false)
+		case c == '$' && sp.dollar && (i == 0 || !isIdentChar(s[i-1])):
+			if tag, ok := dollarTag(s[i:]); ok {
+				if end := strings.Index(s[i+len(tag):], tag); end >= 0 {
+					j = i + len(tag) + end + len(tag)
+				} else {
+					j = len(s)
+				}
+			}
+		}
+
+		line += strings.Count(s[i:j], "\n")
+		i = j
+	}
+
+	emit(len(s))
+	return stmts
+}
+
+// replacePlaceholders replaces each ? placeholder in the given query with the
+// placeholder returned from the given function for its position. Placeholders
+// that appear in string literals, quoted identifiers, or comments are left as
+// is.
+func replacePlaceholders(q string, placeholder func(int) string) string {
+	var buf strings.Builder
+
+	n := 0
+
+	for i := 0; i < len(q); {
+		c := q[i]
+
+		var next byte
+
+		if i+1 < len(q) {
+			next = q[i+1]
+		}
+
+		j := i + 1
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			j = skipQuoted(q, i, c, false)
+		case c == '-' && next == '-':
+			if end := strings.IndexByte(q[i:], '\n'); end >= 0 {
+				j = i + end
+			} else {
+				j = len(q)
+			}
+		case c == '/' && next == '*':
+			if end := strings.Index(q[i+2:], "*/"); end >= 0 {
+				j = i + 2 + end + 2
+			} else {
+				j = len(q)
+			}
+		case c == '?':
+			n++
+			buf.WriteString(placeholder(n))
+			i = j
+			continue
+		}
+
+		buf.WriteString(q[i:j])
+		i = j
+	}
+	return buf.String()
+}
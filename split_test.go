@@ -0,0 +1,80 @@
+package mgrt
+
+import "testing"
+
+func Test_Split(t *testing.T) {
+	tests := []struct {
+		sp       splitter
+		sql      string
+		expected []Statement
+	}{
+		{
+			mysqlSplitter,
+			"CREATE TABLE a ( id INT );\nCREATE TABLE b ( id INT );",
+			[]Statement{
+				{"CREATE TABLE a ( id INT )", 1},
+				{"CREATE TABLE b ( id INT )", 2},
+			},
+		},
+		{
+			mysqlSplitter,
+			"INSERT INTO a VALUES ('a;b', \"c;d\", `e;f`, 'it\\'s;');",
+			[]Statement{
+				{"INSERT INTO a VALUES ('a;b', \"c;d\", `e;f`, 'it\\'s;')", 1},
+			},
+		},
+		{
+			mysqlSplitter,
+			"-- comment;\n# comment;\n/* comment;\n*/\nSELECT 1;\n-- trailing comment",
+			[]Statement{
+				{"SELECT 1", 5},
+			},
+		},
+		{
+			mysqlSplitter,
+			"DELIMITER $$\nCREATE PROCEDURE p()\nBEGIN\n  SELECT 1;\nEND$$\nDELIMITER ;\nCALL p();",
+			[]Statement{
+				{"CREATE PROCEDURE p()\nBEGIN\n  SELECT 1;\nEND", 2},
+				{"CALL p()", 7},
+			},
+		},
+		{
+			postgresSplitter,
+			"CREATE FUNCTION f() RETURNS INT AS $body$\nBEGIN\n  RETURN 1;\nEND;\n$body$ LANGUAGE plpgsql;\n\nSELECT E'a\\';b', 'c'';d';",
+			[]Statement{
+				{"CREATE FUNCTION f() RETURNS INT AS $body$\nBEGIN\n  RETURN 1;\nEND;\n$body$ LANGUAGE plpgsql", 1},
+				{"SELECT E'a\\';b', 'c'';d'", 7},
+			},
+		},
+		{
+			postgresSplitter,
+			"SELECT $1;\nSELECT $$a;b$$;",
+			[]Statement{
+				{"SELECT $1", 1},
+				{"SELECT $$a;b$$", 2},
+			},
+		},
+		{
+			splitter{backtick: true, triggers: true},
+			"CREATE TRIGGER t AFTER INSERT ON a\nBEGIN\n  UPDATE a SET n = CASE WHEN n > 0 THEN n END;\n  DELETE FROM b;\nEND;\nDROP TABLE c;",
+			[]Statement{
+				{"CREATE TRIGGER t AFTER INSERT ON a\nBEGIN\n  UPDATE a SET n = CASE WHEN n > 0 THEN n END;\n  DELETE FROM b;\nEND", 1},
+				{"DROP TABLE c", 6},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		stmts := test.sp.split(test.sql)
+
+		if len(stmts) != len(test.expected) {
+			t.Fatalf("tests[%d] - unexpected statement count, expected=%d, got=%d\n%v\n", i, len(test.expected), len(stmts), stmts)
+		}
+
+		for j, stmt := range stmts {
+			if stmt != test.expected[j] {
+				t.Errorf("tests[%d] - unexpected statement %d, expected=%v, got=%v\n", i, j, test.expected[j], stmt)
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package mgrt
+
+import (
+	"context"
+	"sort"
+)
+
+// State is the state of a Revision in a database.
+type State uint
+
+const (
+	StateApplied State = iota // StateApplied is a Revision that has been performed.
+	StatePending              // StatePending is a Revision that has not been performed.
+	StateMissing              // StateMissing is a performed Revision that does not exist locally.
+)
+
+// RevisionStatus is the status of a single Revision in a database.
+type RevisionStatus struct {
+	Revision *Revision
+	State    State
+}
+
+// StatusReport reports the status of the revisions in a database, grouped by
+// category.
+type StatusReport struct {
+	// Categories is the sorted list of categories of the revisions in the
+	// report. Revisions without a category are under the empty string.
+	Categories []string
+
+	// Revisions is the status of each Revision grouped by category, each
+	// group is in ascending order of Revision ID.
+	Revisions map[string][]*RevisionStatus
+}
+
+// Status returns the status of the given revisions in the given database. A
+// Revision is applied if it has been performed against the database, and
+// pending if not. Any revisions that have been performed against the database,
+// but are not in the given revisions will be reported as missing.
+func Status(db *DB, revs ...*Revision) (*StatusReport, error) {
+	return StatusContext(context.Background(), db, revs...)
+}
+
+// StatusContext returns the status of the given revisions in the given
+// database using the given context. This behaves the same as Status.
+func StatusContext(ctx context.Context, db *DB, revs ...*Revision) (*StatusReport, error) {
+	performed, err := GetRevisionsContext(ctx, db, -1)
+
+	if err != nil {
+		return nil, err
+	}
+
+	logged := make(map[string]struct{}, len(performed))
+
+	for _, rev := range performed {
+		logged[rev.Slug()] = struct{}{}
+	}
+
+	report := &StatusReport{
+		Revisions: make(map[string][]*RevisionStatus),
+	}
+
+	local := make(map[string]struct{}, len(revs))
+
+	for _, rev := range revs {
+		slug := rev.Slug()
+
+		if _, ok := local[slug]; ok {
+			continue
+		}
+
+		local[slug] = struct{}{}
+
+		st := StatePending
+
+		if _, ok := logged[slug]; ok {
+			st = StateApplied
+		}
+
+		report.put(rev, st)
+	}
+
+	for _, rev := range performed {
+		if _, ok := local[rev.Slug()]; !ok {
+			report.put(rev, StateMissing)
+		}
+	}
+
+	sort.Strings(report.Categories)
+
+	for _, statuses := range report.Revisions {
+		sort.Slice(statuses, func(i, j int) bool {
+			return statuses[i].Revision.ID < statuses[j].Revision.ID
+		})
+	}
+	return report, nil
+}
+
+func (r *StatusReport) put(rev *Revision, st State) {
+	if _, ok := r.Revisions[rev.Category]; !ok {
+		r.Categories = append(r.Categories, rev.Category)
+	}
+
+	r.Revisions[rev.Category] = append(r.Revisions[rev.Category], &RevisionStatus{
+		Revision: rev,
+		State:    st,
+	})
+}
+
+// Pending returns the status of all the pending revisions in the report.
+func (r *StatusReport) Pending() []*RevisionStatus {
+	pending := make([]*RevisionStatus, 0)
+
+	for _, category := range r.Categories {
+		for _, st := range r.Revisions[category] {
+			if st.State == StatePending {
+				pending = append(pending, st)
+			}
+		}
+	}
+	return pending
+}
+
+func (s State) String() string {
+	switch s {
+	case StateApplied:
+		return "applied"
+	case StatePending:
+		return "pending"
+	case StateMissing:
+		return "missing"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,88 @@
+package mgrt
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func Test_Status(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	db, err := Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tests := []struct {
+		category string
+		id       string
+		sql      string
+		perform  bool
+		local    bool
+		state    State
+	}{
+		{"", "20060102150405", "CREATE TABLE users ( id INT NOT NULL UNIQUE );", true, true, StateApplied},
+		{"", "20060102150406", "CREATE TABLE posts ( id INT NOT NULL UNIQUE );", false, true, StatePending},
+		{"perms", "20060102150405", "CREATE TABLE roles ( id INT NOT NULL UNIQUE );", true, false, StateMissing},
+		{"perms", "20060102150407", "CREATE TABLE grants ( id INT NOT NULL UNIQUE );", false, true, StatePending},
+	}
+
+	revs := make([]*Revision, 0, len(tests))
+
+	for _, test := range tests {
+		rev := NewRevisionCategory(test.category, "Andrew", "")
+		rev.ID = test.id
+		rev.SQL = test.sql
+
+		if test.perform {
+			if err := rev.Perform(db); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if test.local {
+			revs = append(revs, rev)
+		}
+	}
+
+	report, err := Status(db, revs...)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Categories) != 2 {
+		t.Fatalf("unexpected category count, expected=%d, got=%d\n", 2, len(report.Categories))
+	}
+
+	i := 0
+
+	for _, category := range report.Categories {
+		for _, st := range report.Revisions[category] {
+			test := tests[i]
+
+			if slug := st.Revision.Slug(); slug != (&Revision{ID: test.id, Category: test.category}).Slug() {
+				t.Errorf("tests[%d] - unexpected revision, got=%q\n", i, slug)
+			}
+
+			if st.State != test.state {
+				t.Errorf("tests[%d] - unexpected state, expected=%q, got=%q\n", i, test.state, st.State)
+			}
+			i++
+		}
+	}
+
+	if l := len(report.Pending()); l != 2 {
+		t.Fatalf("unexpected pending count, expected=%d, got=%d\n", 2, l)
+	}
+}
@@ -0,0 +1,129 @@
+package mgrt
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// column is a column that has been added to the revision log table since its
+// original layout.
+type column struct {
+	name string // name is the name of the column.
+	def  string // def is the definition of the column used when adding it.
+}
+
+// schemaVersion is the current version of the layout of the revision log
+// table. This is recorded in the metadata table, and is incremented each time
+// the layout changes.
+const schemaVersion = 4
+
+var (
+	// metaInit creates the table used for recording the version of the layout
+	// of the revision log table.
+	metaInit = `CREATE TABLE IF NOT EXISTS %s (
+	version INT NOT NULL
+);`
+
+	// columns are the columns added to the revision log table since its
+	// original layout, in the order they were added.
+	columns = []column{
+		{"down", "TEXT NOT NULL DEFAULT ''"},
+		{"checksum", "VARCHAR(64) NOT NULL DEFAULT ''"},
+		{"baselined", "BOOLEAN NOT NULL DEFAULT FALSE"},
+		{"duration", "BIGINT NOT NULL DEFAULT 0"},
+		{"version", "VARCHAR NOT NULL DEFAULT ''"},
+		{"performed_by", "VARCHAR NOT NULL DEFAULT ''"},
+		{"no_transaction", "BOOLEAN NOT NULL DEFAULT FALSE"},
+		{"depends", "TEXT NOT NULL DEFAULT ''"},
+		{"marked", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	}
+)
+
+// addColumns adds the given columns to the revision log table if they do not
+// exist. Since this checks for each column, this can be used to upgrade from
+// any version of the layout of the revision log table.
+func addColumns(ctx context.Context, db *DB, cols []column) error {
+	for _, col := range cols {
+		if err := addColumn(ctx, db, col); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasColumn checks to see if the revision log table has the given column.
+func hasColumn(ctx context.Context, db *DB, name string) bool {
+	rows, err := db.QueryContext(ctx, "SELECT "+name+" FROM "+db.tableName()+" WHERE 1 = 0")
+
+	if err != nil {
+		return false
+	}
+
+	rows.Close()
+	return true
+}
+
+// addColumn adds the given column to the revision log table if it does not
+// exist. If the column could not be added because it was added concurrently,
+// then no error is returned.
+func addColumn(ctx context.Context, db *DB, col column) error {
+	if hasColumn(ctx, db, col.name) {
+		return nil
+	}
+
+	if _, err := db.ExecContext(ctx, "ALTER TABLE "+db.tableName()+" ADD COLUMN "+col.name+" "+col.def); err != nil {
+		if hasColumn(ctx, db, col.name) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// metaTable returns the quoted name of the table that the version of the layout
+// of the revision log table is recorded in.
+func (db *DB) metaTable() string { return db.quoteTable("_meta") }
+
+// upgrade upgrades the revision log table to the current version of its
+// layout via the Dialect, if it implements Upgrader, and records the new
+// version in the metadata table. If the revision log table did not exist
+// before it was initialized, then it is already at the current version. If the
+// table is already at the current version, then nothing happens.
+func (db *DB) upgrade(ctx context.Context, exists bool) error {
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(metaInit, db.metaTable())); err != nil {
+		return err
+	}
+
+	var version sql.NullInt64
+
+	if err := db.QueryRowContext(ctx, "SELECT MAX(version) FROM "+db.metaTable()).Scan(&version); err != nil {
+		return err
+	}
+
+	if version.Int64 >= schemaVersion {
+		return nil
+	}
+
+	if u, ok := db.Dialect.(Upgrader); ok && exists {
+		if err := u.Upgrade(ctx, db, int(version.Int64)); err != nil {
+			return fmt.Errorf("upgrade %s: %w", db.qualifiedName(), err)
+		}
+
+		db.logAttrs(ctx, slog.LevelInfo, "revision log upgraded",
+			slog.String("table", db.qualifiedName()),
+			slog.Int64("from", version.Int64),
+			slog.Int("to", schemaVersion),
+		)
+	}
+
+	if _, err := db.ExecContext(ctx, "DELETE FROM "+db.metaTable()); err != nil {
+		return err
+	}
+
+	q := db.parameterize("INSERT INTO " + db.metaTable() + " (version) VALUES (?)")
+
+	_, err := db.ExecContext(ctx, q, schemaVersion)
+	return err
+}
@@ -0,0 +1,95 @@
+package mgrt
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func Test_OpenUpgrade(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "mgrt-db-*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	sqldb, err := sql.Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The original layout of the revision log table in v3.
+	schema := `CREATE TABLE mgrt_revisions (
+	id           VARCHAR NOT NULL,
+	author       VARCHAR NOT NULL,
+	comment      TEXT NOT NULL,
+	sql          TEXT NOT NULL,
+	performed_at INT NOT NULL
+);`
+
+	if _, err := sqldb.Exec(schema); err != nil {
+		t.Fatal(err)
+	}
+
+	q := "INSERT INTO mgrt_revisions (id, author, comment, sql, performed_at) VALUES (?, ?, ?, ?, ?)"
+
+	if _, err := sqldb.Exec(q, "20060102150405", "Andrew", "Add users table", "CREATE TABLE users ( id INT NOT NULL UNIQUE );", 1136214245); err != nil {
+		t.Fatal(err)
+	}
+
+	sqldb.Close()
+
+	// Opening twice ensures the upgrade is idempotent.
+	for i := 0; i < 2; i++ {
+		db, err := Open("sqlite3", tmp.Name())
+
+		if err != nil {
+			t.Fatalf("open %d - %s\n", i, err)
+		}
+
+		var version int
+
+		if err := db.QueryRow("SELECT version FROM mgrt_revisions_meta").Scan(&version); err != nil {
+			t.Fatalf("open %d - %s\n", i, err)
+		}
+
+		if version != schemaVersion {
+			t.Fatalf("open %d - unexpected version, expected=%d, got=%d\n", i, schemaVersion, version)
+		}
+		db.Close()
+	}
+
+	db, err := Open("sqlite3", tmp.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	rev, err := GetRevision(db, "20060102150405")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rev.Checksum != "" || rev.Down != "" || rev.Baselined {
+		t.Errorf("unexpected upgraded revision, got=%+v\n", rev)
+	}
+
+	rev2 := NewRevision("Andrew", "Add posts table")
+	rev2.ID = "20060102150406"
+	rev2.SQL = "CREATE TABLE posts ( id INT NOT NULL UNIQUE );"
+
+	if err := rev2.Perform(db); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyRevisions(db, rev, rev2); err != nil {
+		t.Fatal(err)
+	}
+}